@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 1000
+	defaultTopLimit  = 10
+)
+
+// getHTTPListenAddr returns the listen address of the HTTP server, empty to disable it.
+// It is disabled by default as the incident API and UI are not authenticated.
+func getHTTPListenAddr() string {
+	addr := os.Getenv("HTTP_LISTEN_ADDR")
+	if addr == "" {
+		klog.Warning("Environment variable HTTP_LISTEN_ADDR is not set, default: disabled\n")
+	}
+	return addr
+}
+
+// ListenAndServe serves the read-only incident API, the web UI and the Slack interactivity and slash command endpoints.
+func (c *Controller) ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	if c.slack.isInteractive() {
+		mux.HandleFunc("/slack/interactivity", c.handleSlackInteraction)
+		mux.HandleFunc("/slack/commands", c.handleSlashCommand)
+	}
+	if c.incidents != nil {
+		mux.HandleFunc("/api/v1/incidents", c.handleListIncidents)
+		mux.HandleFunc("/api/v1/incidents/top", c.handleTopIncidents)
+		mux.HandleFunc("/api/v1/incidents/", c.handleGetIncident)
+		mux.Handle("/", uiHandler())
+	}
+	klog.Infof("HTTP server: listening on %s\n", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+// handleListIncidents lists the incidents matching the query, the newest first.
+// Query: channel, namespace, workload, pod, container, reason, node, status, since, until, offset, limit.
+func (c *Controller) handleListIncidents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+		return
+	}
+	query := r.URL.Query()
+	filter, err := parseIncidentFilter(query)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	offset, err := parseQueryInt(query, "offset", 0, 0)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit, err := parseQueryInt(query, "limit", defaultPageLimit, maxPageLimit)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	incidents, total, err := c.incidents.List(filter, offset, limit)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"total":     total,
+		"offset":    offset,
+		"limit":     limit,
+		"incidents": incidents,
+	})
+}
+
+// handleGetIncident returns the incident with its full context, the id is the last path segment.
+func (c *Controller) handleGetIncident(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/api/v1/incidents/")
+	incident, err := c.incidents.Get(id)
+	if errors.Is(err, ErrInvalidIncidentID) {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if incident == nil {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("incident %q not found", id))
+		return
+	}
+	writeJSON(w, incident)
+}
+
+// handleTopIncidents returns the workloads, reasons or nodes with the most incidents matching the query.
+// Query: by (workload, reason or node), limit, and the filters of handleListIncidents.
+func (c *Controller) handleTopIncidents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+		return
+	}
+	query := r.URL.Query()
+	filter, err := parseIncidentFilter(query)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit, err := parseQueryInt(query, "limit", defaultTopLimit, maxPageLimit)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	by := query.Get("by")
+	if by == "" {
+		by = "workload"
+	}
+
+	top, err := c.incidents.Top(filter, by, limit)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"by":    by,
+		"items": top,
+	})
+}
+
+// parseIncidentFilter parses the filter query, since and until are RFC3339 times or durations before now (e.g. 168h).
+func parseIncidentFilter(query url.Values) (IncidentFilter, error) {
+	filter := IncidentFilter{
+		Channel:   query.Get("channel"),
+		Namespace: query.Get("namespace"),
+		Workload:  query.Get("workload"),
+		Pod:       query.Get("pod"),
+		Container: query.Get("container"),
+		Reason:    query.Get("reason"),
+		Node:      query.Get("node"),
+		Status:    query.Get("status"),
+	}
+	var err error
+	if filter.Since, err = parseQueryTime(query, "since"); err != nil {
+		return filter, err
+	}
+	if filter.Until, err = parseQueryTime(query, "until"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+func parseQueryTime(query url.Values, name string) (time.Time, error) {
+	value := query.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("invalid %s %q, expected RFC3339 time or duration", name, value)
+	}
+	return t, nil
+}
+
+// parseQueryInt parses a non-negative integer query, capped by max if max > 0.
+func parseQueryInt(query url.Values, name string, defaultValue int, max int) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q, expected non-negative integer", name, value)
+	}
+	if max > 0 && n > max {
+		n = max
+	}
+	return n, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.Errorf("Failed while writing response: %v", err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// serveAPI sends the request to the handler and decodes the JSON response.
+func serveAPI(t *testing.T, handler http.HandlerFunc, method, target string) (int, map[string]interface{}) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(method, target, nil))
+	var body map[string]interface{}
+	if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+		t.Fatalf("%s %s: invalid JSON response: %v", method, target, err)
+	}
+	return recorder.Code, body
+}
+
+func TestIncidentAPI(t *testing.T) {
+	store := newTestIncidentStore(t)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var ids []string
+	for _, incident := range []IncidentRecord{
+		{Pod: "api-1", Namespace: "payments", WorkloadKind: "Deployment", Workload: "api", Reason: "OOMKilled", Status: IncidentNotified, Time: base},
+		{Pod: "api-2", Namespace: "payments", WorkloadKind: "Deployment", Workload: "api", Reason: "Error", Status: IncidentNotified, Time: base.Add(time.Hour)},
+		{Pod: "web-0", Namespace: "web", WorkloadKind: "StatefulSet", Workload: "web", Reason: "OOMKilled", Status: IncidentMuted, Time: base.Add(2 * time.Hour), Context: "logs"},
+	} {
+		incident := incident
+		if err := store.Add(&incident); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, incident.ID)
+	}
+	c := &Controller{incidents: store}
+
+	t.Run("list", func(t *testing.T) {
+		code, body := serveAPI(t, c.handleListIncidents, http.MethodGet, "/api/v1/incidents?namespace=payments&limit=1")
+		if code != http.StatusOK {
+			t.Fatalf("list status = %d, body = %v", code, body)
+		}
+		incidents := body["incidents"].([]interface{})
+		if body["total"] != float64(2) || len(incidents) != 1 || incidents[0].(map[string]interface{})["pod"] != "api-2" {
+			t.Errorf("list = %v, want the newest of 2 payments incidents", body)
+		}
+	})
+	t.Run("list with invalid filters", func(t *testing.T) {
+		for _, target := range []string{"/api/v1/incidents?since=yesterday", "/api/v1/incidents?limit=-1"} {
+			if code, body := serveAPI(t, c.handleListIncidents, http.MethodGet, target); code != http.StatusBadRequest || body["error"] == nil {
+				t.Errorf("GET %s = %d %v, want a bad request", target, code, body)
+			}
+		}
+		if code, _ := serveAPI(t, c.handleListIncidents, http.MethodPost, "/api/v1/incidents"); code != http.StatusMethodNotAllowed {
+			t.Errorf("POST /api/v1/incidents = %d, want %d", code, http.StatusMethodNotAllowed)
+		}
+	})
+	t.Run("get", func(t *testing.T) {
+		code, body := serveAPI(t, c.handleGetIncident, http.MethodGet, "/api/v1/incidents/"+ids[2])
+		if code != http.StatusOK || body["pod"] != "web-0" || body["context"] != "logs" {
+			t.Errorf("get = %d %v, want the web-0 incident with its context", code, body)
+		}
+		if code, _ := serveAPI(t, c.handleGetIncident, http.MethodGet, "/api/v1/incidents/not-an-id"); code != http.StatusBadRequest {
+			t.Errorf("get invalid id = %d, want %d", code, http.StatusBadRequest)
+		}
+	})
+	t.Run("top", func(t *testing.T) {
+		code, body := serveAPI(t, c.handleTopIncidents, http.MethodGet, "/api/v1/incidents/top?by=reason")
+		if code != http.StatusOK {
+			t.Fatalf("top status = %d, body = %v", code, body)
+		}
+		items := body["items"].([]interface{})
+		first := items[0].(map[string]interface{})
+		if len(items) != 2 || first["key"] != "OOMKilled" || first["count"] != float64(2) {
+			t.Errorf("top = %v, want OOMKilled first with 2 incidents", body)
+		}
+		if code, _ := serveAPI(t, c.handleTopIncidents, http.MethodGet, "/api/v1/incidents/top?by=image"); code != http.StatusBadRequest {
+			t.Errorf("top by image = %d, want %d", code, http.StatusBadRequest)
+		}
+	})
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// maxArchiveLogBytes limits the logs kept from the end of each container logs into the archive.
+	maxArchiveLogBytes = 50 * 1024 * 1024
+	// archiveTimeout limits the time to read the logs and upload one archive.
+	archiveTimeout = 5 * time.Minute
+	// archivePresignTimeout limits the time to get the URL of the uploaded archive.
+	archivePresignTimeout = 10 * time.Second
+	// archivePartSize is the part size of the streamed multipart upload, the minimum part size of S3.
+	archivePartSize = 5 * 1024 * 1024
+	// maxConcurrentArchives is the number of workers collecting and uploading the archives.
+	maxConcurrentArchives = 2
+	// maxQueuedArchives limits the archives waiting for a worker, the next archives are dropped.
+	maxQueuedArchives = 20
+)
+
+// Archiver uploads the incident bundles to an S3-compatible bucket.
+type Archiver struct {
+	Enabled   bool
+	Bucket    string
+	Prefix    string        // The object key prefix
+	URLBase   string        // The internal URL of the bucket, presigned URLs are used if empty
+	URLExpiry time.Duration // The expiry of the presigned URLs
+	client    *minio.Client
+	queue     chan archiveJob // The archives waiting for a worker
+}
+
+// archiveJob is an archive of a restarted container, the link is sent in the alert thread once it is uploaded.
+type archiveJob struct {
+	key      string
+	pod      *v1.Pod
+	status   v1.ContainerStatus
+	thread   SlackThread
+	incident string // The incident store id of the alert, empty if the store is disabled
+	alerted  time.Time
+}
+
+// archiveFile is a file of the incident bundle, it is opened when it is written to the archive so only one file
+// is open at a time.
+type archiveFile struct {
+	name string
+	// open returns the redacted content and its size
+	open func() (io.ReadCloser, int64, error)
+}
+
+// NewArchiver creates the Archiver from the environment variables, it is disabled if the endpoint or the bucket is not set.
+func NewArchiver() (Archiver, error) {
+	endpoint := os.Getenv("ARCHIVE_S3_ENDPOINT")
+	archiver := Archiver{
+		Bucket:  os.Getenv("ARCHIVE_S3_BUCKET"),
+		Prefix:  os.Getenv("ARCHIVE_S3_PREFIX"),
+		URLBase: strings.TrimSuffix(os.Getenv("ARCHIVE_URL_BASE"), "/"),
+	}
+	if endpoint == "" || archiver.Bucket == "" {
+		klog.Info("Incident archive: disabled, ARCHIVE_S3_ENDPOINT or ARCHIVE_S3_BUCKET is not set\n")
+		return archiver, nil
+	}
+
+	expirySeconds, err := strconv.Atoi(os.Getenv("ARCHIVE_URL_EXPIRY_SECONDS"))
+	if err != nil {
+		// The maximum expiry of AWS Signature Version 4
+		expirySeconds = 7 * 24 * 3600
+		klog.Warningf("Environment variable ARCHIVE_URL_EXPIRY_SECONDS is not set, default: %d\n", expirySeconds)
+	}
+	archiver.URLExpiry = time.Duration(expirySeconds) * time.Second
+
+	// Static credentials if set, or the IAM role of the pod
+	creds := credentials.NewIAM("")
+	if accessKeyID := os.Getenv("ARCHIVE_S3_ACCESS_KEY_ID"); accessKeyID != "" {
+		creds = credentials.NewStaticV4(accessKeyID, os.Getenv("ARCHIVE_S3_SECRET_ACCESS_KEY"), "")
+	}
+	archiver.client, err = minio.New(endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: os.Getenv("ARCHIVE_S3_INSECURE") != "true",
+		Region: os.Getenv("ARCHIVE_S3_REGION"),
+	})
+	if err != nil {
+		return archiver, fmt.Errorf("got error while creating the archive client for %s: %v", endpoint, err)
+	}
+	archiver.queue = make(chan archiveJob, maxQueuedArchives)
+	archiver.Enabled = true
+	klog.Infof("Incident archive: endpoint: %s, bucket: %s, prefix: %s\n", endpoint, archiver.Bucket, archiver.Prefix)
+	return archiver, nil
+}
+
+// getURL returns the URL of the archive.
+func (archiver Archiver) getURL(key string) (string, error) {
+	if archiver.URLBase != "" {
+		return archiver.URLBase + "/" + key, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), archivePresignTimeout)
+	defer cancel()
+	presignedURL, err := archiver.client.PresignedGetObject(ctx, archiver.Bucket, key, archiver.URLExpiry, url.Values{})
+	if err != nil {
+		return "", fmt.Errorf("got error while presigning %s: %v", key, err)
+	}
+	return presignedURL.String(), nil
+}
+
+// upload streams the files compressed into a tar.gz archive to the bucket, without buffering the archive.
+func (archiver Archiver) upload(ctx context.Context, key string, files []archiveFile) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeArchive(pw, files))
+	}()
+	_, err := archiver.client.PutObject(ctx, archiver.Bucket, key, pr, -1, minio.PutObjectOptions{
+		ContentType: "application/gzip",
+		PartSize:    archivePartSize,
+	})
+	// Unblock the writer if the upload failed before reading the whole archive
+	pr.CloseWithError(err)
+	if err != nil {
+		return fmt.Errorf("got error while uploading %s to bucket %s: %v", key, archiver.Bucket, err)
+	}
+	return nil
+}
+
+// writeArchive writes the files as a tar.gz archive, the files which cannot be opened are skipped.
+func writeArchive(out io.Writer, files []archiveFile) error {
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	modTime := time.Now()
+	for _, file := range files {
+		content, size, err := file.open()
+		if err != nil {
+			klog.Warningf("Failed while archiving %s, skipped: %v", file.name, err)
+			continue
+		}
+		err = writeArchiveFile(tw, file.name, content, size, modTime)
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeArchiveFile(tw *tar.Writer, name string, content io.Reader, size int64, modTime time.Time) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("got error while archiving %s: %v", name, err)
+	}
+	if _, err := io.Copy(tw, content); err != nil {
+		return fmt.Errorf("got error while archiving %s: %v", name, err)
+	}
+	return nil
+}
+
+// archiveIncident queues the incident bundle of the alerted container, the bundle contains the pod YAML, the container
+// statuses, the full previous and current logs, the pod events, the node YAML and the owner workload YAML.
+// The archive is dropped if the queue is full, so a slow bucket does not hold the pods in memory.
+func (c *Controller) archiveIncident(pod *v1.Pod, status v1.ContainerStatus, thread SlackThread, record *IncidentRecord) {
+	if !c.archiver.Enabled {
+		return
+	}
+	job := archiveJob{
+		key: fmt.Sprintf("%s%s/%s/%s/%s-%s.tar.gz", c.archiver.Prefix, c.slack.ClusterName, pod.Namespace, pod.Name,
+			record.Time.UTC().Format("20060102T150405Z"), status.Name),
+		pod:      pod.DeepCopy(),
+		status:   status,
+		thread:   thread,
+		incident: record.ID,
+		alerted:  record.Time,
+	}
+	select {
+	case c.archiver.queue <- job:
+	default:
+		klog.Warningf("Failed while archiving %s/%s incident: %d archives are queued, dropped", pod.Namespace, pod.Name, maxQueuedArchives)
+	}
+}
+
+// runArchiveWorker uploads the queued archives until the stop channel is closed.
+func (c *Controller) runArchiveWorker(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case job := <-c.archiver.queue:
+			c.uploadArchive(job)
+		}
+	}
+}
+
+// uploadArchive uploads the archive bounded by archiveTimeout, and sends its link once it is uploaded.
+func (c *Controller) uploadArchive(job archiveJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), archiveTimeout)
+	defer cancel()
+	pod := job.pod
+	if err := c.archiver.upload(ctx, job.key, c.getArchiveFiles(ctx, pod, job.status)); err != nil {
+		klog.Errorf("Failed while archiving %s/%s incident: %v", pod.Namespace, pod.Name, err)
+		return
+	}
+	klog.Infof("Archived: %s/%s incident to %s\n", pod.Namespace, pod.Name, job.key)
+
+	archiveURL, err := c.archiver.getURL(job.key)
+	if err != nil {
+		klog.Errorf("Failed while linking %s/%s incident archive: %v", pod.Namespace, pod.Name, err)
+		return
+	}
+	if c.incidents != nil && job.incident != "" {
+		if err := c.incidents.SetArchiveURL(job.incident, archiveURL); err != nil {
+			klog.Errorf("Failed while recording %s/%s incident archive: %v", pod.Namespace, pod.Name, err)
+		}
+	}
+	if err := c.sendArchiveLink(job, archiveURL); err != nil {
+		klog.Errorf("Failed while sending %s/%s incident archive link: %v", pod.Namespace, pod.Name, err)
+	}
+}
+
+// sendArchiveLink sends the link of the uploaded archive as a reply of the alert in bot mode, or a follow-up in webhook mode.
+func (c *Controller) sendArchiveLink(job archiveJob, archiveURL string) error {
+	text := printArchiveURL(archiveURL)
+	if job.thread.Timestamp == "" {
+		text += fmt.Sprintf("• Container: `%s`, Alerted: `%s`\n", job.status.Name, job.alerted.Format(time.RFC1123Z))
+	}
+	msg := SlackMessage{
+		Title:  fmt.Sprintf("*Incident archived!*\n*cluster: `%s`, pod: `%s`, namespace: `%s`*", c.slack.ClusterName, job.pod.Name, job.pod.Namespace),
+		Text:   text,
+		Footer: fmt.Sprintf("%s, %s, %s", c.slack.ClusterName, job.pod.Name, job.pod.Namespace),
+	}
+	_, err := c.slack.sendToThread(msg, job.thread)
+	return err
+}
+
+// getArchiveFiles returns the redacted files of the incident bundle, the logs are read when they are archived.
+func (c *Controller) getArchiveFiles(ctx context.Context, pod *v1.Pod, status v1.ContainerStatus) []archiveFile {
+	var files []archiveFile
+	addYAML := func(name string, obj interface{}) {
+		content, err := yaml.Marshal(obj)
+		if err != nil {
+			klog.Warningf("Failed while marshaling %s of %s/%s: %v", name, pod.Namespace, pod.Name, err)
+			return
+		}
+		files = append(files, archiveFile{name: name, open: func() (io.ReadCloser, int64, error) {
+			redacted, _ := c.redactor.redact(string(content))
+			return ioutil.NopCloser(strings.NewReader(redacted)), int64(len(redacted)), nil
+		}})
+	}
+	addLogs := func(name string, containerName string, previous bool) {
+		files = append(files, archiveFile{name: name, open: func() (io.ReadCloser, int64, error) {
+			return c.openArchiveLogs(ctx, pod, containerName, previous)
+		}})
+	}
+
+	podObj := pod.DeepCopy()
+	podObj.APIVersion, podObj.Kind = "v1", "Pod"
+	podObj.ManagedFields = nil
+	c.redactor.redactEnv(podObj)
+	addYAML("pod.yaml", podObj)
+	addYAML("container-statuses.yaml", map[string][]v1.ContainerStatus{
+		"initContainerStatuses": pod.Status.InitContainerStatuses,
+		"containerStatuses":     pod.Status.ContainerStatuses,
+	})
+
+	if status.LastTerminationState.Terminated != nil {
+		addLogs(fmt.Sprintf("logs/%s.previous.log", status.Name), status.Name, true)
+	}
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		addLogs(fmt.Sprintf("logs/%s.log", containerStatus.Name), containerStatus.Name, false)
+	}
+
+	events, err := c.getPodEvents(ctx, pod)
+	if err != nil {
+		klog.Warningf("Failed while getting %s/%s events: %v", pod.Namespace, pod.Name, err)
+	}
+	for i := range events {
+		events[i].LastTimestamp = getEventLastTimestamp(events[i])
+		events[i].ManagedFields = nil
+	}
+	if len(events) > 1 {
+		sort.Sort(byLastTimestamp(events))
+	}
+	addYAML("events.yaml", events)
+
+	if pod.Spec.NodeName != "" {
+		node, err := c.clientset.CoreV1().Nodes().Get(ctx, pod.Spec.NodeName, metav1.GetOptions{})
+		if err != nil {
+			klog.Warningf("Failed while getting the %s Node: %v", pod.Spec.NodeName, err)
+		} else {
+			node.APIVersion, node.Kind = "v1", "Node"
+			node.ManagedFields = nil
+			addYAML("node.yaml", node)
+		}
+	}
+
+	owner, err := c.getOwnerWorkload(pod)
+	if err != nil {
+		klog.Warningf("Failed while getting %s/%s owner workload: %v", pod.Namespace, pod.Name, err)
+	} else if owner != nil {
+		c.redactor.redactEnv(owner)
+		addYAML("owner.yaml", owner)
+	}
+	return files
+}
+
+// openArchiveLogs streams the container logs without the tail limit of the log policy, redacted line by line into a
+// temporary file, and returns the last maxArchiveLogBytes of the file, which is removed once it is closed.
+// The error of the logs request is returned as the content.
+func (c *Controller) openArchiveLogs(ctx context.Context, pod *v1.Pod, containerName string, previous bool) (io.ReadCloser, int64, error) {
+	logOptions := &v1.PodLogOptions{
+		Container:  containerName,
+		Previous:   previous,
+		Timestamps: true,
+	}
+	rc, err := c.clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, logOptions).Stream(ctx)
+	if err != nil {
+		klog.Warningf("Failed while getting %s/%s container %s logs: %v", pod.Namespace, pod.Name, containerName, err)
+		content := fmt.Sprintf("got error while getting logs: %v\n", err)
+		return ioutil.NopCloser(strings.NewReader(content)), int64(len(content)), nil
+	}
+	defer rc.Close()
+
+	file, err := ioutil.TempFile("", "archive-*.log")
+	if err != nil {
+		return nil, 0, err
+	}
+	tempFile := &archiveTempFile{file: file}
+	if _, err := c.redactor.redactStream(file, rc); err != nil {
+		// The logs read before the error are archived
+		klog.Warningf("Failed while reading %s/%s container %s logs: %v", pod.Namespace, pod.Name, containerName, err)
+	}
+	offset, size, err := getLastBytesOffset(file, maxArchiveLogBytes)
+	if err != nil {
+		tempFile.Close()
+		return nil, 0, err
+	}
+	tempFile.reader = io.NewSectionReader(file, offset, size)
+	return tempFile, size, nil
+}
+
+// archiveTempFile reads a section of a temporary file, which is removed once it is closed.
+type archiveTempFile struct {
+	file   *os.File
+	reader io.Reader
+}
+
+func (tempFile *archiveTempFile) Read(p []byte) (int, error) {
+	return tempFile.reader.Read(p)
+}
+
+func (tempFile *archiveTempFile) Close() error {
+	err := tempFile.file.Close()
+	os.Remove(tempFile.file.Name())
+	return err
+}
+
+// getLastBytesOffset returns the offset and the size of the last limitBytes of the file, cut at the start of a line
+// like readLastBytes.
+func getLastBytesOffset(file *os.File, limitBytes int64) (int64, int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	size := info.Size()
+	if size <= limitBytes {
+		return 0, size, nil
+	}
+	offset := size - limitBytes
+	// The cut is after the first newline from the byte before the offset, the end of the last line is kept
+	// if it is longer than the limit
+	reader := bufio.NewReader(io.NewSectionReader(file, offset-1, limitBytes+1))
+	var skipped int64
+	for {
+		line, err := reader.ReadSlice('\n')
+		skipped += int64(len(line))
+		if err == nil {
+			if skipped < limitBytes+1 {
+				offset += skipped - 1
+			}
+			break
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != bufio.ErrBufferFull {
+			return 0, 0, err
+		}
+	}
+	return offset, size - offset, nil
+}
+
+// printArchiveURL prints the link to the incident archive, empty if there is no archive.
+func printArchiveURL(archiveURL string) string {
+	if archiveURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("• Full Context: <%s|incident archive>\n", archiveURL)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// readArchive returns the files of the tar.gz archive.
+func readArchive(t *testing.T, archive []byte) map[string]string {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	files := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[header.Name] = string(content)
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	stringFile := func(name, content string) archiveFile {
+		return archiveFile{name: name, open: func() (io.ReadCloser, int64, error) {
+			return ioutil.NopCloser(strings.NewReader(content)), int64(len(content)), nil
+		}}
+	}
+	files := []archiveFile{
+		stringFile("pod.yaml", "kind: Pod\n"),
+		{name: "logs/app.log", open: func() (io.ReadCloser, int64, error) { return nil, 0, errors.New("disk full") }},
+		stringFile("logs/app.previous.log", "line 1\n"),
+	}
+	var archive bytes.Buffer
+	if err := writeArchive(&archive, files); err != nil {
+		t.Fatal(err)
+	}
+	got := readArchive(t, archive.Bytes())
+	// The files which cannot be opened are skipped
+	if len(got) != 2 || got["pod.yaml"] != "kind: Pod\n" || got["logs/app.previous.log"] != "line 1\n" {
+		t.Errorf("writeArchive() files = %v", got)
+	}
+}
+
+func TestGetLastBytesOffset(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		limit   int64
+		want    string
+	}{
+		{"under the limit", "a\nb\n", 10, "a\nb\n"},
+		{"cut at the start of a line", "aaa\nbbb\n", 4, "bbb\n"},
+		{"partial line dropped", "aaa\nbbb\nccc\n", 6, "ccc\n"},
+		{"last line longer than the limit", "aaa\nbbbbbbbb\n", 4, "bbb\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := ioutil.TempFile(t.TempDir(), "logs")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			if _, err := file.WriteString(tt.content); err != nil {
+				t.Fatal(err)
+			}
+			offset, size, err := getLastBytesOffset(file, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.content[offset : offset+size]; got != tt.want {
+				t.Errorf("getLastBytesOffset() = %q, want %q", got, tt.want)
+			}
+			// The same cut as the logs of the alerts
+			if logs, _ := readLastBytes(strings.NewReader(tt.content), tt.limit); logs != tt.want {
+				t.Errorf("readLastBytes() = %q, want %q", logs, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetArchiveFiles(t *testing.T) {
+	controller := true
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "default",
+			Name:            "api-7d4b9c-x2k4p",
+			Labels:          map[string]string{"pod-template-hash": "7d4b9c"},
+			OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "api-7d4b9c", Controller: &controller}},
+		},
+		Spec: v1.PodSpec{Containers: []v1.Container{{Name: "app", Env: []v1.EnvVar{{Name: "DB_PASSWORD", Value: "hunter22"}}}}},
+		Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{{
+			Name:                 "app",
+			LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}},
+		}}},
+	}
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Namespace:       "default",
+		Name:            "api-7d4b9c",
+		OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "api", Controller: &controller}},
+	}}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api"},
+		Spec: appsv1.DeploymentSpec{Template: v1.PodTemplateSpec{Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: "app", Env: []v1.EnvVar{{Name: "DB_PASSWORD", Value: "hunter22"}}}},
+		}}},
+	}
+	clientset := fake.NewSimpleClientset(pod, replicaSet, deployment)
+	filterEventsByUID(clientset)
+	c := &Controller{clientset: clientset, redactor: Redactor{Enabled: true, rules: builtinRedactionRules}}
+	tempDir := t.TempDir()
+	os.Setenv("TMPDIR", tempDir)
+	defer os.Unsetenv("TMPDIR")
+
+	var archive bytes.Buffer
+	if err := writeArchive(&archive, c.getArchiveFiles(context.Background(), pod, pod.Status.ContainerStatuses[0])); err != nil {
+		t.Fatal(err)
+	}
+	files := readArchive(t, archive.Bytes())
+	for _, name := range []string{"pod.yaml", "container-statuses.yaml", "logs/app.previous.log", "logs/app.log", "events.yaml", "owner.yaml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("getArchiveFiles() has no %s, files: %v", name, files)
+		}
+	}
+	if files["logs/app.previous.log"] != "fake logs" {
+		t.Errorf("getArchiveFiles() previous logs = %q", files["logs/app.previous.log"])
+	}
+	for _, name := range []string{"pod.yaml", "owner.yaml"} {
+		if strings.Contains(files[name], "hunter22") || !strings.Contains(files[name], redactedEnvValue) {
+			t.Errorf("getArchiveFiles() %s env values are not redacted:\n%s", name, files[name])
+		}
+	}
+	if !strings.Contains(files["owner.yaml"], "kind: Deployment") {
+		t.Errorf("getArchiveFiles() owner.yaml:\n%s", files["owner.yaml"])
+	}
+	// The logs are redacted into temporary files, which are removed once archived
+	if matches, _ := filepath.Glob(filepath.Join(tempDir, "archive-*.log")); len(matches) > 0 {
+		t.Errorf("getArchiveFiles() left the temporary files %v", matches)
+	}
+}
+
+func TestArchiveIncidentQueue(t *testing.T) {
+	c := &Controller{archiver: Archiver{Enabled: true, queue: make(chan archiveJob, maxQueuedArchives)}}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-0"}}
+	record := &IncidentRecord{ID: "0001", Time: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)}
+	for i := 0; i < maxQueuedArchives+5; i++ {
+		c.archiveIncident(pod, v1.ContainerStatus{Name: "app"}, SlackThread{}, record)
+	}
+	// The archives are dropped once the queue is full
+	if len(c.archiver.queue) != maxQueuedArchives {
+		t.Errorf("archiveIncident() queued %d archives, want %d", len(c.archiver.queue), maxQueuedArchives)
+	}
+	job := <-c.archiver.queue
+	if job.key != "/default/web-0/20240101T100000Z-app.tar.gz" || job.incident != "0001" {
+		t.Errorf("archiveIncident() job key = %q, incident = %q", job.key, job.incident)
+	}
+}
+
+func TestSendArchiveLink(t *testing.T) {
+	slack, webhook := newTestSlack(t, http.StatusOK)
+	c := &Controller{slack: slack}
+	job := archiveJob{
+		pod:     &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-0"}},
+		status:  v1.ContainerStatus{Name: "app"},
+		alerted: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+	}
+	if err := c.sendArchiveLink(job, "https://minio/incidents/web-0.tar.gz"); err != nil {
+		t.Fatal(err)
+	}
+	sent := webhook.sent()
+	if len(sent) != 1 {
+		t.Fatalf("sendArchiveLink() sent %d messages, want 1", len(sent))
+	}
+	want := "• Full Context: <https://minio/incidents/web-0.tar.gz|incident archive>\n• Container: `app`, Alerted: `Mon, 01 Jan 2024 10:00:00 +0000`\n"
+	if sent[0].Text != want {
+		t.Errorf("sendArchiveLink() text = %q, want %q", sent[0].Text, want)
+	}
+}
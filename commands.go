@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// stringsFlag is a repeated string flag.
+type stringsFlag []string
+
+func (values *stringsFlag) String() string {
+	return strings.Join(*values, ",")
+}
+
+func (values *stringsFlag) Set(value string) error {
+	*values = append(*values, value)
+	return nil
+}
+
+// runCommand runs the command, and returns the exit code: 2 for the usage errors, 1 for the other errors.
+func runCommand(flags *flag.FlagSet, args []string, run func() error) int {
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runDiagnoseCommand prints the report of a pod like the restart alerts, without sending it.
+func runDiagnoseCommand(args []string) int {
+	flags := flag.NewFlagSet("diagnose", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: k8s-pod-restart-info-collector diagnose [flags] <namespace>/<pod>")
+		flags.PrintDefaults()
+	}
+	kubeconfig := addKubeconfigFlag(flags)
+	configFile := flags.String("config", getConfigFile(), "path of the configuration file, default: CONFIG_FILE")
+	containerName := flags.String("container", "", "the container, default: the kubectl.kubernetes.io/default-container annotation or the first container")
+	output := flags.String("output", "text", "the output format, text, json or html")
+	clusterName := flags.String("cluster-name", os.Getenv("CLUSTER_NAME"), "the cluster name shown in the report, default: CLUSTER_NAME")
+	return runCommand(flags, args, func() error {
+		if flags.NArg() != 1 {
+			flags.Usage()
+			return fmt.Errorf("expected <namespace>/<pod>")
+		}
+		render, ok := incidentRenderers[*output]
+		if !ok {
+			return fmt.Errorf("unknown output format %q", *output)
+		}
+		namespace, name, err := cache.SplitMetaNamespaceKey(flags.Arg(0))
+		if err != nil || namespace == "" || name == "" {
+			return fmt.Errorf("expected <namespace>/<pod>, got %q", flags.Arg(0))
+		}
+		collectorConfig, err := LoadConfig(*configFile)
+		if err != nil {
+			return err
+		}
+		config, err := buildRestConfig(*kubeconfig)
+		if err != nil {
+			return err
+		}
+		clientset, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return err
+		}
+		dynamicClient, err := dynamic.NewForConfig(config)
+		if err != nil {
+			return err
+		}
+
+		// Fail fast before syncing the caches of the cluster
+		pod, err := clientset.CoreV1().Pods(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if *containerName == "" {
+			*containerName = getDefaultContainerName(pod)
+		}
+		status, ok := getContainerStatus(pod, *containerName)
+		if !ok {
+			return fmt.Errorf("container %s of pod %s/%s not found", *containerName, namespace, name)
+		}
+
+		slack := Slack{ClusterName: *clusterName, History: make(map[string]time.Time)}
+		controller := NewController(clientset, dynamicClient, slack, collectorConfig)
+		stop := make(chan struct{})
+		defer close(stop)
+		if !controller.startInformers(stop) {
+			return fmt.Errorf("timed out waiting for caches to sync")
+		}
+		incident, err := controller.collectIncident(pod, status)
+		if err != nil {
+			return err
+		}
+		incident.Title = "Pod report"
+		return render(os.Stdout, incident)
+	})
+}
+
+// runTestNotifyCommand sends a synthetic incident to the default channel and the channels of the configuration file.
+func runTestNotifyCommand(args []string) int {
+	flags := flag.NewFlagSet("test-notify", flag.ContinueOnError)
+	configFile := flags.String("config", getConfigFile(), "path of the configuration file, default: CONFIG_FILE")
+	var channels stringsFlag
+	flags.Var(&channels, "channel", "an additional Slack channel, e.g. of the alert-slack-channel annotations, can be repeated")
+	return runCommand(flags, args, func() error {
+		collectorConfig, err := LoadConfig(*configFile)
+		if err != nil {
+			return err
+		}
+		messageTemplates, err := NewMessageTemplates(collectorConfig.MessageTemplates)
+		if err != nil {
+			return err
+		}
+		slack := NewSlack()
+		incident, err := newTestIncident(slack.ClusterName)
+		if err != nil {
+			return err
+		}
+
+		var failed int
+		for _, channel := range getConfiguredChannels(slack, collectorConfig, channels) {
+			msg, err := messageTemplates.render(incident, channel, renderSlackMessage(incident))
+			if err != nil {
+				fmt.Printf("FAILED  %s: %v\n", channel, err)
+				failed++
+				continue
+			}
+			if err := slack.sendToChannel(msg, channel); err != nil {
+				fmt.Printf("FAILED  %s: %v\n", channel, err)
+				failed++
+				continue
+			}
+			fmt.Printf("SENT    %s\n", channel)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d destinations failed", failed)
+		}
+		return nil
+	})
+}
+
+// runValidateConfigCommand validates the configuration file like the collector at startup.
+func runValidateConfigCommand(args []string) int {
+	flags := flag.NewFlagSet("validate-config", flag.ContinueOnError)
+	configFile := flags.String("config", getConfigFile(), "path of the configuration file, default: CONFIG_FILE")
+	return runCommand(flags, args, func() error {
+		if *configFile == "" {
+			return fmt.Errorf("the configuration file is not set, use -config or CONFIG_FILE")
+		}
+		collectorConfig, err := LoadConfig(*configFile)
+		if err != nil {
+			return err
+		}
+		if err := validateConfig(collectorConfig); err != nil {
+			return err
+		}
+		fmt.Printf("%s is valid: %d diagnoses, %d log error patterns, %d redaction rules, %d digests, %d maintenance windows, %d slash command channels, %d message templates\n",
+			*configFile, len(collectorConfig.Diagnoses), len(collectorConfig.LogErrorPatterns), len(collectorConfig.RedactionRules),
+			len(collectorConfig.Digests), len(collectorConfig.MaintenanceWindows), len(collectorConfig.SlashCommandChannels), len(collectorConfig.MessageTemplates))
+		return nil
+	})
+}
+
+// validateConfig validates every section of the configuration file.
+func validateConfig(config Config) error {
+	if _, err := NewDiagnosisCatalog(config.Diagnoses); err != nil {
+		return err
+	}
+	if _, err := NewLogAnalyzer(config.LogErrorPatterns); err != nil {
+		return err
+	}
+	if _, err := NewRedactor(config.RedactionRules); err != nil {
+		return err
+	}
+	if err := validateDigestSchedules(config.Digests); err != nil {
+		return err
+	}
+	if _, err := NewMaintenance(config.MaintenanceWindows); err != nil {
+		return err
+	}
+	if _, err := NewSlashCommandAccess(config.SlashCommandChannels); err != nil {
+		return err
+	}
+	if _, err := NewMessageTemplates(config.MessageTemplates); err != nil {
+		return err
+	}
+	return nil
+}
+
+// getConfiguredChannels returns the default channel, the channels of the configuration file and the extra channels, sorted.
+func getConfiguredChannels(slack Slack, config Config, extra []string) []string {
+	seen := map[string]bool{slack.DefaultChannel: true}
+	for _, digest := range config.Digests {
+		seen[digest.Channel] = true
+	}
+	for _, window := range config.MaintenanceWindows {
+		seen[window.Channel] = true
+	}
+	for _, messageTemplate := range config.MessageTemplates {
+		seen[messageTemplate.Channel] = true
+	}
+	for _, channel := range extra {
+		seen[channel] = true
+	}
+	delete(seen, "")
+	channels := make([]string, 0, len(seen))
+	for channel := range seen {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// newTestIncident creates the incident of a synthetic restart, rendered like the real alerts.
+func newTestIncident(clusterName string) (*Incident, error) {
+	now := metav1.Now()
+	container := v1.Container{
+		Name:  "app",
+		Image: "example.com/test-notify:latest",
+		Resources: v1.ResourceRequirements{
+			Limits:   v1.ResourceList{v1.ResourceMemory: resource.MustParse("256Mi")},
+			Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m"), v1.ResourceMemory: resource.MustParse("256Mi")},
+		},
+	}
+	status := v1.ContainerStatus{
+		Name:         container.Name,
+		Image:        container.Image,
+		RestartCount: 1,
+		Ready:        true,
+		State:        v1.ContainerState{Running: &v1.ContainerStateRunning{StartedAt: now}},
+		LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+			Reason:     "OOMKilled",
+			ExitCode:   137,
+			StartedAt:  metav1.NewTime(now.Add(-time.Hour)),
+			FinishedAt: now,
+		}},
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-notify", Namespace: "test-notify", Labels: map[string]string{"app": "test-notify"}, CreationTimestamp: metav1.NewTime(now.Add(-time.Hour))},
+		Spec:       v1.PodSpec{NodeName: "test-node", Containers: []v1.Container{container}},
+		Status: v1.PodStatus{
+			Phase:             v1.PodRunning,
+			ContainerStatuses: []v1.ContainerStatus{status},
+		},
+	}
+
+	incident := &Incident{
+		Title:        "Pod restarted! (test notification)",
+		Cluster:      clusterName,
+		Namespace:    pod.Namespace,
+		Pod:          pod.Name,
+		Container:    container.Name,
+		Owner:        IncidentOwner{Kind: "Deployment", Name: "test-notify"},
+		Labels:       pod.Labels,
+		Node:         pod.Spec.NodeName,
+		Image:        container.Image,
+		RestartCount: status.RestartCount,
+		Restarted:    true,
+		Reason:       status.LastTerminationState.Terminated.Reason,
+		ExitCode:     status.LastTerminationState.Terminated.ExitCode,
+		RunDuration:  time.Hour,
+		Logs:         "This is a synthetic incident sent by the test-notify command.\n",
+	}
+	var err error
+	if incident.PodStatus, err = printPod(pod); err != nil {
+		return nil, err
+	}
+	if incident.ContainerState, err = describeContainerState(status); err != nil {
+		return nil, err
+	}
+	if incident.Resources, err = getContainerResource(container); err != nil {
+		return nil, err
+	}
+	return incident, nil
+}
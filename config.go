@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the collector configuration file, for the settings which do not fit in environment variables.
+type Config struct {
+	// Diagnoses extend the built-in diagnosis catalog, they are evaluated before the built-in rules.
+	Diagnoses []DiagnosisRule `json:"diagnoses,omitempty"`
+	// LogErrorPatterns are the regular expressions of the error lines extracted from the logs,
+	// they replace the default patterns.
+	LogErrorPatterns []string `json:"logErrorPatterns,omitempty"`
+	// RedactionRules are applied after the built-in redaction rules.
+	RedactionRules []RedactionRule `json:"redactionRules,omitempty"`
+	// Digests are the scheduled summaries of the recorded incidents per Slack channel.
+	Digests []DigestSchedule `json:"digests,omitempty"`
+	// MaintenanceWindows are the times the restarts are recorded but not notified,
+	// in addition to the windows of the namespace annotation.
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+	// SlashCommandChannels are the namespaces allowed in the /restartinfo slash command per Slack channel.
+	SlashCommandChannels []SlashCommandChannel `json:"slashCommandChannels,omitempty"`
+	// MessageTemplates override the title, text and footer of the pod restart alerts, globally or per Slack channel.
+	MessageTemplates []MessageTemplate `json:"messageTemplates,omitempty"`
+}
+
+// getConfigFile returns the path of the configuration file, empty if not set.
+func getConfigFile() string {
+	return os.Getenv("CONFIG_FILE")
+}
+
+// LoadConfig loads the configuration file, an empty configuration is returned if path is empty.
+func LoadConfig(path string) (Config, error) {
+	var config Config
+	if path == "" {
+		klog.Info("Environment variable CONFIG_FILE is not set, using the default configuration\n")
+		return config, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("got error while reading config file %s: %v", path, err)
+	}
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return config, fmt.Errorf("got error while parsing config file %s: %v", path, err)
+	}
+	klog.Infof("Loaded config file %s\n", path)
+	return config, nil
+}
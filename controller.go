@@ -1,47 +1,113 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	batchinformers "k8s.io/client-go/informers/batch/v1"
 	coreinformers "k8s.io/client-go/informers/core/v1"
 
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/util/duration"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
-	"k8s.io/utils/pointer"
 )
 
 const (
 	SlackChannelKey = "alert-slack-channel"
+	// NodeNameIndex is the pod informer index of pods by node name
+	NodeNameIndex = "spec.nodeName"
 )
 
 type Controller struct {
-	clientset       kubernetes.Interface
-	slack           Slack
-	informerFactory informers.SharedInformerFactory
-	podInformer     coreinformers.PodInformer
-	queue           workqueue.RateLimitingInterface
+	clientset         kubernetes.Interface
+	slack             Slack
+	diagnoses         DiagnosisCatalog
+	logAnalyzer       LogAnalyzer
+	logPolicy         LogPolicy
+	redactor          Redactor
+	archiver          Archiver
+	incidents         *IncidentStore
+	recoveries        *RecoveryTracker
+	silences          *Silences
+	mutes             *WorkloadMutes
+	slashCommands     SlashCommandAccess
+	messageTemplates  *MessageTemplates
+	maintenance       *Maintenance
+	nodeIncidents     NodeIncidents
+	eventsWindow      time.Duration
+	informerFactory   informers.SharedInformerFactory
+	podInformer       coreinformers.PodInformer
+	namespaceInformer coreinformers.NamespaceInformer
+	jobInformer       batchinformers.JobInformer
+	queue             workqueue.RateLimitingInterface
 }
 
 // NewController creates a new Controller.
-func NewController(clientset kubernetes.Interface, slack Slack) *Controller {
+func NewController(clientset kubernetes.Interface, dynamicClient dynamic.Interface, slack Slack, config Config) *Controller {
 	const resyncPeriod = 0
 	ignoreRestartCount := getIgnoreRestartCount()
 
+	diagnoses, err := NewDiagnosisCatalog(config.Diagnoses)
+	if err != nil {
+		klog.Exit(err)
+	}
+	logAnalyzer, err := NewLogAnalyzer(config.LogErrorPatterns)
+	if err != nil {
+		klog.Exit(err)
+	}
+	redactor, err := NewRedactor(config.RedactionRules)
+	if err != nil {
+		klog.Exit(err)
+	}
+	archiver, err := NewArchiver()
+	if err != nil {
+		klog.Exit(err)
+	}
+	incidents, err := NewIncidentStore()
+	if err != nil {
+		klog.Exit(err)
+	}
+	maintenance, err := NewMaintenance(config.MaintenanceWindows)
+	if err != nil {
+		klog.Exit(err)
+	}
+	slashCommands, err := NewSlashCommandAccess(config.SlashCommandChannels)
+	if err != nil {
+		klog.Exit(err)
+	}
+	messageTemplates, err := NewMessageTemplates(config.MessageTemplates)
+	if err != nil {
+		klog.Exit(err)
+	}
+
 	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
 	informerFactory := informers.NewSharedInformerFactory(clientset, resyncPeriod)
 	podInformer := informerFactory.Core().V1().Pods()
+	err = podInformer.Informer().AddIndexers(cache.Indexers{
+		NodeNameIndex: func(obj interface{}) ([]string, error) {
+			pod, ok := obj.(*v1.Pod)
+			if !ok || pod.Spec.NodeName == "" {
+				return []string{}, nil
+			}
+			return []string{pod.Spec.NodeName}, nil
+		},
+	})
+	if err != nil {
+		klog.Fatal(err)
+	}
 	podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		UpdateFunc: func(old interface{}, new interface{}) {
 			oldPod, ok := old.(*v1.Pod)
@@ -80,12 +146,66 @@ func NewController(clientset kubernetes.Interface, slack Slack) *Controller {
 		},
 	})
 
+	// The namespaces are cached for their maintenance windows annotation
+	namespaceInformer := informerFactory.Core().V1().Namespaces()
+
+	var jobInformer batchinformers.JobInformer
+	if shouldReportFailedJobs() {
+		jobInformer = informerFactory.Batch().V1().Jobs()
+		jobInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(old interface{}, new interface{}) {
+				oldJob, ok := old.(*batchv1.Job)
+				if !ok {
+					return
+				}
+
+				newJob, ok := new.(*batchv1.Job)
+				if !ok {
+					return
+				}
+
+				// The pod name prefixes are checked on the failed pods of the Job
+				if !isWatchedNamespace(newJob.Namespace) || isIgnoredNamespace(newJob.Namespace) {
+					return
+				}
+
+				// Only report the transition to failed
+				if _, failed := getJobFailedReason(oldJob); failed {
+					return
+				}
+				if reason, failed := getJobFailedReason(newJob); failed {
+					key, err := cache.MetaNamespaceKeyFunc(new)
+					if err == nil {
+						queue.Add(jobKey(key))
+					}
+					klog.Infof("Found: job %s/%s failed, reason: %s\n", newJob.Namespace, newJob.Name, reason)
+				}
+			},
+		})
+	}
+
 	return &Controller{
-		clientset:       clientset,
-		informerFactory: informerFactory,
-		podInformer:     podInformer,
-		queue:           queue,
-		slack:           slack,
+		clientset:         clientset,
+		informerFactory:   informerFactory,
+		podInformer:       podInformer,
+		namespaceInformer: namespaceInformer,
+		jobInformer:       jobInformer,
+		queue:             queue,
+		slack:             slack,
+		diagnoses:         diagnoses,
+		logAnalyzer:       logAnalyzer,
+		logPolicy:         NewLogPolicy(),
+		redactor:          redactor,
+		archiver:          archiver,
+		incidents:         incidents,
+		recoveries:        NewRecoveryTracker(),
+		silences:          NewSilences(dynamicClient, clientset.Discovery()),
+		mutes:             NewWorkloadMutes(),
+		slashCommands:     slashCommands,
+		messageTemplates:  messageTemplates,
+		maintenance:       maintenance,
+		nodeIncidents:     NewNodeIncidents(),
+		eventsWindow:      getEventsWindow(),
 	}
 }
 
@@ -97,12 +217,8 @@ func (c *Controller) Run(workers int, stopCh chan struct{}) {
 	defer c.queue.ShutDown()
 	klog.Info("Starting controller")
 
-	// Starts all the shared informers that have been created by the factory so
-	// far.
-	go c.informerFactory.Start(stopCh)
-
 	// Wait for all involved caches to be synced, before processing items from the queue is started
-	if !cache.WaitForCacheSync(stopCh, c.podInformer.Informer().HasSynced) {
+	if !c.startInformers(stopCh) {
 		runtime.HandleError(fmt.Errorf("timed out waiting for caches to sync"))
 		return
 	}
@@ -110,6 +226,18 @@ func (c *Controller) Run(workers int, stopCh chan struct{}) {
 	for i := 0; i < workers; i++ {
 		go wait.Until(c.runWorker, time.Second, stopCh)
 	}
+	if c.archiver.Enabled {
+		for i := 0; i < maxConcurrentArchives; i++ {
+			go c.runArchiveWorker(stopCh)
+		}
+	}
+	if c.recoveries.Enabled {
+		go wait.Until(c.checkRecoveries, recoveryCheckInterval, stopCh)
+	}
+	if c.silences.Enabled {
+		go wait.Until(c.checkExpiredSilences, silenceCheckInterval, stopCh)
+	}
+	go wait.Until(c.checkClosedMaintenance, maintenanceCheckInterval, stopCh)
 
 	klog.Info("Started controller")
 
@@ -117,6 +245,24 @@ func (c *Controller) Run(workers int, stopCh chan struct{}) {
 	klog.Info("Stopping controller")
 }
 
+// startInformers starts all the shared informers that have been created by the factories so far,
+// and waits for their caches to be synced.
+func (c *Controller) startInformers(stopCh chan struct{}) bool {
+	go c.informerFactory.Start(stopCh)
+	if c.silences.Enabled {
+		go c.silences.factory.Start(stopCh)
+	}
+
+	cacheSyncs := []cache.InformerSynced{c.podInformer.Informer().HasSynced, c.namespaceInformer.Informer().HasSynced}
+	if c.jobInformer != nil {
+		cacheSyncs = append(cacheSyncs, c.jobInformer.Informer().HasSynced)
+	}
+	if c.silences.Enabled {
+		cacheSyncs = append(cacheSyncs, c.silences.informer.Informer().HasSynced)
+	}
+	return cache.WaitForCacheSync(stopCh, cacheSyncs...)
+}
+
 func (c *Controller) runWorker() {
 	for c.processNextItem() {
 	}
@@ -132,7 +278,13 @@ func (c *Controller) processNextItem() bool {
 	defer c.queue.Done(key)
 
 	// Invoke the method containing the business logic
-	err := c.getAndHandlePod(key.(string))
+	var err error
+	switch k := key.(type) {
+	case jobKey:
+		err = c.getAndHandleJob(string(k))
+	default:
+		err = c.getAndHandlePod(key.(string))
+	}
 	// Handle the error if something went wrong during the execution of the business logic
 	c.handleErr(err, key)
 	return true
@@ -150,7 +302,7 @@ func (c *Controller) handleErr(err error, key interface{}) {
 
 	// This controller retries 3 times if something goes wrong. After that, it stops trying.
 	if c.queue.NumRequeues(key) < 3 {
-		klog.Infof("Error syncing Pod %v: %v", key, err)
+		klog.Infof("Error syncing %v: %v", key, err)
 
 		// Re-enqueue the key rate limited. Based on the rate limiter on the
 		// queue and the re-enqueue history, the key will be processed later again.
@@ -161,7 +313,7 @@ func (c *Controller) handleErr(err error, key interface{}) {
 	c.queue.Forget(key)
 	// Report to an external entity that, even after several retries, we could not successfully process this key
 	runtime.HandleError(err)
-	klog.Infof("Dropping Pod %q out of the queue: %v", key, err)
+	klog.Infof("Dropping %q out of the queue: %v", key, err)
 }
 
 // getAndHandlePod is the business logic of the controller.
@@ -201,16 +353,8 @@ func (c *Controller) getPodFromIndexer(key string) (*v1.Pod, error) {
 
 // handlePod collects and sends related info to slack.
 func (c *Controller) handlePod(pod *v1.Pod) error {
-	// Skip if pod in slack.History
 	podKey := pod.Namespace + "/" + pod.Name
-
 	currentTime := time.Now().Local()
-	if lastSentTime, ok := c.slack.History[podKey]; ok {
-		if int(currentTime.Sub(lastSentTime).Seconds()) < c.slack.MuteSeconds {
-			klog.Infof("Skip: %s, already sent %s ago.\n", podKey, duration.HumanDuration(time.Since(lastSentTime)))
-			return nil
-		}
-	}
 
 	// check and collect restarted container info
 	for _, status := range pod.Status.ContainerStatuses {
@@ -223,69 +367,72 @@ func (c *Controller) handlePod(pod *v1.Pod) error {
 			continue
 		}
 
-		klog.Infof("Handle: %s restarted, restartCount: %d\n", podKey, status.RestartCount)
+		record := c.newIncidentRecord(pod, status)
 
-		podInfo, err := printPod(pod)
-		if err != nil {
-			return err
+		if silence := c.silences.match(pod, status, currentTime); silence != nil {
+			klog.Infof("Silenced: %s by RestartSilence %s\n", podKey, silence.Name)
+			c.silences.suppress(silence)
+			c.recordIncident(record, IncidentSilenced)
+			return nil
 		}
 
-		containerState, err := describeContainerState(status)
-		if err != nil {
-			return err
+		if window := c.checkMaintenance(record, currentTime); window != nil {
+			klog.Infof("Maintenance: %s restarted during maintenance window %s\n", podKey, window)
+			c.recordIncident(record, IncidentMaintenance)
+			return nil
 		}
 
-		restartReason := printContainerLastStateReason(status)
+		// The muted pods are counted, their restarts are part of a node incident too
+		if c.correlateNodeRestart(pod) {
+			c.recordIncident(record, IncidentSuppressed)
+			break
+		}
 
-		var containerSpec v1.Container
-		for _, container := range pod.Spec.Containers {
-			if status.Name == container.Name {
-				containerSpec = container
-				break
+		// Skip if pod in slack.History
+		if lastSentTime, ok := c.slack.History[podKey]; ok {
+			if int(currentTime.Sub(lastSentTime).Seconds()) < c.slack.MuteSeconds {
+				klog.Infof("Skip: %s, already sent %s ago.\n", podKey, duration.HumanDuration(time.Since(lastSentTime)))
+				c.recordIncident(record, IncidentMuted)
+				return nil
 			}
 		}
-		containerResource, err := getContainerResource(containerSpec)
-		if err != nil {
-			return err
+		// Skip if the workload is muted by the alert button
+		if until, muted := c.mutes.isMuted(record.Namespace+"/"+record.WorkloadKind+"/"+record.Workload, currentTime); muted {
+			klog.Infof("Skip: %s, workload muted for %s.\n", podKey, duration.HumanDuration(until.Sub(currentTime)))
+			c.recordIncident(record, IncidentMuted)
+			return nil
 		}
 
-		podStatus := fmt.Sprintf("```%s```\n• Reason: `%s`\n• Pod Status\n```\n%s%s```\n", podInfo, restartReason, containerState, containerResource)
-		podEvents, err := c.getPodEvents(pod)
-		if err != nil {
-			return err
-		}
-		nodeEvents, err := c.getNodeAndEvents(pod)
-		if err != nil {
-			return err
-		}
+		klog.Infof("Handle: %s restarted, restartCount: %d\n", podKey, status.RestartCount)
 
-		containerLogs, err := c.getContainerLogs(pod, status)
+		incident, err := c.collectIncident(pod, status)
 		if err != nil {
 			return err
 		}
-		if containerLogs == "" {
-			containerLogs = "• No Logs Before Restart\n"
-		} else {
-			// Slack attachment text will be truncated when > 8000 chars
-			maxLogLength := 7500 - len(podStatus+podEvents+nodeEvents)
-			if maxLogLength > 0 && len(containerLogs) > maxLogLength {
-				containerLogs = containerLogs[len(containerLogs)-maxLogLength:]
-			}
-			containerLogs = fmt.Sprintf("• Pod Logs Before Restart\n```\n%s```\n", containerLogs)
-		}
+		incident.Title = "Pod restarted!"
 
-		msg := SlackMessage{
-			Title:  fmt.Sprintf("*Pod restarted!*\n*cluster: `%s`, pod: `%s`, namespace: `%s`*", c.slack.ClusterName, pod.Name, pod.Namespace),
-			Text:   podStatus + podEvents + nodeEvents + containerLogs,
-			Footer: fmt.Sprintf("%s, %s, %s", c.slack.ClusterName, pod.Name, pod.Namespace),
+		slackChannel := getSlackChannelFromPod(pod)
+		msg := renderSlackMessage(incident)
+		// The message is sent with the default format if the templates fail
+		if templated, err := c.messageTemplates.render(incident, c.slack.getChannel(slackChannel), msg); err != nil {
+			klog.Errorf("Failed while rendering %s message templates: %v", podKey, err)
+		} else {
+			msg = templated
 		}
+		msg.Actions = c.getAlertActions(pod, status)
 		// klog.Infoln(msg.Title + "\n" + msg.Text + "\n" + msg.Footer)
-		slackChannel := getSlackChannelFromPod(pod)
-		err = c.slack.sendToChannel(msg, slackChannel)
+		thread, err := c.slack.sendToThread(msg, SlackThread{Channel: slackChannel})
 		if err != nil {
 			return err
 		}
-
+		c.recoveries.trackAlert(pod, status, thread)
+
+		// The incident store keeps the logs which do not fit in the message
+		record.Title = msg.Title
+		record.Context = renderSlackText(incident, 0)
+		c.recordIncident(record, IncidentNotified)
+		// The archive is best effort, its link is sent once it is uploaded
+		c.archiveIncident(pod, status, thread, record)
 		c.slack.History[podKey] = currentTime
 		c.cleanOldSlackHistory()
 		break
@@ -293,76 +440,123 @@ func (c *Controller) handlePod(pod *v1.Pod) error {
 	return nil
 }
 
-func (c *Controller) getPodEvents(pod *v1.Pod) (out string, err error) {
-	events, err := c.clientset.CoreV1().Events(pod.Namespace).List(context.TODO(), metav1.ListOptions{FieldSelector: "type!=Normal"})
+// getPodEvents lists the events of the pod by its UID, so the events of a previous pod with the same name
+// are not included.
+func (c *Controller) getPodEvents(ctx context.Context, pod *v1.Pod) ([]v1.Event, error) {
+	eventList, err := c.clientset.CoreV1().Events(pod.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("involvedObject.uid", string(pod.UID)).String(),
+	})
 	if err != nil {
-		klog.Error("Failed while getting Pod events.")
-		return "", fmt.Errorf("got error while getting events: %v", err)
+		return nil, fmt.Errorf("got error while getting events: %v", err)
 	}
-	// Sort events by their last timestamp
-	sortedEvents := events.Items
-	if len(sortedEvents) > 1 {
-		sort.Sort(byLastTimestamp(sortedEvents))
+	return eventList.Items, nil
+}
+
+// listPodEvents lists the recent relevant events of the pod, sorted by their last timestamp.
+func (c *Controller) listPodEvents(pod *v1.Pod) ([]v1.Event, error) {
+	podEvents, err := c.getPodEvents(context.TODO(), pod)
+	if err != nil {
+		return nil, err
 	}
-	for _, event := range sortedEvents {
-		if event.InvolvedObject.Name == pod.Name {
-			out = out + fmt.Sprintf("%s, %s, %s\n", event.LastTimestamp, event.Reason, event.Message)
+
+	since := time.Now().Add(-c.eventsWindow)
+	var events []v1.Event
+	for _, event := range podEvents {
+		if !isRelevantPodEvent(&event) {
+			continue
 		}
+		event.LastTimestamp = getEventLastTimestamp(event)
+		if event.LastTimestamp.Time.Before(since) {
+			continue
+		}
+		events = append(events, event)
 	}
-	if out == "" {
-		out = "• No Warning Pod Events\n"
-	} else {
-		out = fmt.Sprintf("• Pod Events\n```\n%s```\n", out)
+
+	// Sort events by their last timestamp
+	if len(events) > 1 {
+		sort.Sort(byLastTimestamp(events))
 	}
-	return out, nil
+	return events, nil
+}
+
+// isRelevantPodEvent returns true for Warning events, and Normal events explaining restarts
+// such as Killing and probe-related messages.
+func isRelevantPodEvent(event *v1.Event) bool {
+	if event.Type != v1.EventTypeNormal {
+		return true
+	}
+	return event.Reason == "Killing" || strings.Contains(strings.ToLower(event.Message), "probe")
 }
 
-func (c *Controller) getNodeAndEvents(pod *v1.Pod) (out string, err error) {
-	node, err := c.clientset.CoreV1().Nodes().Get(context.TODO(), pod.Spec.NodeName, metav1.GetOptions{})
+// getNodePods gets the pods scheduled on the node from the pod informer.
+func (c *Controller) getNodePods(nodeName string) []*v1.Pod {
+	objs, err := c.podInformer.Informer().GetIndexer().ByIndex(NodeNameIndex, nodeName)
 	if err != nil {
-		klog.Errorf("Failed while getting the %s Node. Probably was deleted. ", pod.Spec.NodeName)
-		return "", err
+		klog.Errorf("Failed while getting pods on the %s Node: %v", nodeName, err)
+		return nil
+	}
+	pods := make([]*v1.Pod, 0, len(objs))
+	for _, obj := range objs {
+		if pod, ok := obj.(*v1.Pod); ok {
+			pods = append(pods, pod)
+		}
 	}
-	out, _ = printNode(node)
+	return pods
+}
 
-	events, err := c.clientset.CoreV1().Events(metav1.NamespaceDefault).List(context.TODO(), metav1.ListOptions{FieldSelector: "involvedObject.kind=Node"})
+func (c *Controller) getNodeEvents(nodeName string) (out string, err error) {
+	events, err := c.listNodeEvents(nodeName)
 	if err != nil {
-		return "", fmt.Errorf("got error while getting events: %v", err)
+		return "", err
 	}
-	// Sort events by their last timestamp
-	sortedEvents := events.Items
-	if len(sortedEvents) > 1 {
-		sort.Sort(byLastTimestamp(sortedEvents))
+	for _, event := range events {
+		out = out + fmt.Sprintf("%s, %s, %s\n", event.LastTimestamp, event.Reason, event.Message)
 	}
-	for _, event := range sortedEvents {
-		if event.InvolvedObject.Name == pod.Spec.NodeName {
-			out = out + fmt.Sprintf("%s, %s, %s\n", event.LastTimestamp, event.Reason, event.Message)
+	return out, nil
+}
+
+// listNodeEvents lists the recent events of the node in all namespaces, sorted by their last timestamp.
+// The events recorded with the events.k8s.io/v1 API are served by the core/v1 API too.
+func (c *Controller) listNodeEvents(nodeName string) ([]v1.Event, error) {
+	eventList, err := c.clientset.CoreV1().Events(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+		FieldSelector: fields.Set{"involvedObject.kind": "Node", "involvedObject.name": nodeName}.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("got error while getting events: %v", err)
+	}
+
+	since := time.Now().Add(-c.eventsWindow)
+	var events []v1.Event
+	for _, event := range eventList.Items {
+		event.LastTimestamp = getEventLastTimestamp(event)
+		if event.LastTimestamp.Time.Before(since) {
+			continue
 		}
+		events = append(events, event)
 	}
 
-	out = fmt.Sprintf("• Node Status and Events\n```\n%s```\n", out)
-	return out, nil
+	// Sort events by their last timestamp
+	if len(events) > 1 {
+		sort.Sort(byLastTimestamp(events))
+	}
+	return events, nil
 }
 
-// getContainerLogs gets previous terminated container logs
-func (c *Controller) getContainerLogs(pod *v1.Pod, containerStatus v1.ContainerStatus) (out string, err error) {
-	logOptions := &v1.PodLogOptions{
-		Container:  containerStatus.Name,
-		Previous:   true,
-		Timestamps: true,
-		TailLines:  pointer.Int64Ptr(50),
+// getContainerLogs gets container logs following the pod log policy, previous is set to get the previous terminated container logs
+func (c *Controller) getContainerLogs(pod *v1.Pod, containerStatus v1.ContainerStatus, previous bool) (out string, err error) {
+	terminated := containerStatus.State.Terminated
+	if previous {
+		terminated = containerStatus.LastTerminationState.Terminated
 	}
+	policy := c.getLogPolicy(pod)
+	logOptions := policy.podLogOptions(containerStatus.Name, terminated, previous, c.logAnalyzer.TailLines)
 	rc, err := c.clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, logOptions).Stream(context.TODO())
 	if err != nil {
 		klog.Errorf("got error while getting %s logs: %v", pod.Name, err)
 		return "", fmt.Errorf("got error while getting logs: %v", err)
 	}
 	defer rc.Close()
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(rc)
-
-	out = buf.String()
-	return out, err
+	return readLastBytes(rc, policy.LimitBytes)
 }
 
 // cleanOldSlackHistory deletes old pod name from the c.slack.History.
@@ -377,10 +571,15 @@ func (c *Controller) cleanOldSlackHistory() {
 
 // getSlackChannelFromPod gets custom slack channel from pod annotations or labels.
 func getSlackChannelFromPod(pod *v1.Pod) string {
-	if slackChannel, ok := pod.GetAnnotations()[SlackChannelKey]; ok {
+	return getSlackChannelFromObject(pod)
+}
+
+// getSlackChannelFromObject gets custom slack channel from object annotations or labels.
+func getSlackChannelFromObject(obj metav1.Object) string {
+	if slackChannel, ok := obj.GetAnnotations()[SlackChannelKey]; ok {
 		return slackChannel
 	}
-	if slackChannel, ok := pod.GetLabels()[SlackChannelKey]; ok {
+	if slackChannel, ok := obj.GetLabels()[SlackChannelKey]; ok {
 		return slackChannel
 	}
 	return ""
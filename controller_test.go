@@ -0,0 +1,133 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// filterEventsByUID serves the event lists of the fake clientset filtered by the involvedObject.uid field selector,
+// which the fake object tracker ignores.
+func filterEventsByUID(clientset *fake.Clientset) {
+	clientset.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		uid, ok := action.(k8stesting.ListAction).GetListRestrictions().Fields.RequiresExactMatch("involvedObject.uid")
+		if !ok {
+			return false, nil, nil
+		}
+		obj, err := clientset.Tracker().List(v1.SchemeGroupVersion.WithResource("events"), v1.SchemeGroupVersion.WithKind("Event"), action.GetNamespace())
+		if err != nil {
+			return true, nil, err
+		}
+		list := obj.(*v1.EventList)
+		var items []v1.Event
+		for _, event := range list.Items {
+			if string(event.InvolvedObject.UID) == uid {
+				items = append(items, event)
+			}
+		}
+		list.Items = items
+		return true, list, nil
+	})
+}
+
+func TestListNodeEvents(t *testing.T) {
+	now := time.Now()
+	nodeEvent := func(namespace, name, reason string, lastTimestamp time.Time) *v1.Event {
+		return &v1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Namespace: namespace, Name: name},
+			InvolvedObject: v1.ObjectReference{Kind: "Node", Name: "node-1"},
+			Reason:         reason,
+			LastTimestamp:  metav1.NewTime(lastTimestamp),
+		}
+	}
+	// Recorded with the events.k8s.io API, only the event time is set
+	newEvent := &v1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: "kube-system", Name: "new"},
+		InvolvedObject: v1.ObjectReference{Kind: "Node", Name: "node-1"},
+		Reason:         "SystemOOM",
+		EventTime:      metav1.NewMicroTime(now.Add(-time.Minute)),
+	}
+	clientset := fake.NewSimpleClientset(
+		nodeEvent("default", "ready", "NodeReady", now.Add(-10*time.Minute)),
+		nodeEvent("kube-system", "pressure", "NodeHasInsufficientMemory", now.Add(-30*time.Minute)),
+		nodeEvent("default", "old", "Rebooted", now.Add(-2*time.Hour)),
+		newEvent,
+	)
+	c := &Controller{clientset: clientset, eventsWindow: time.Hour}
+
+	events, err := c.listNodeEvents("node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reasons []string
+	for _, event := range events {
+		reasons = append(reasons, event.Reason)
+	}
+	// The events of all namespaces within the window, sorted by their last timestamp
+	want := []string{"NodeHasInsufficientMemory", "NodeReady", "SystemOOM"}
+	if !reflect.DeepEqual(reasons, want) {
+		t.Errorf("listNodeEvents() reasons = %v, want %v", reasons, want)
+	}
+	if events[2].LastTimestamp.IsZero() {
+		t.Errorf("listNodeEvents() did not set the last timestamp of the events.k8s.io event")
+	}
+
+	// One field selector query per lookup
+	actions := clientset.Actions()
+	if len(actions) != 1 {
+		t.Fatalf("listNodeEvents() made %d API calls, want 1", len(actions))
+	}
+	list, ok := actions[0].(k8stesting.ListAction)
+	if !ok || list.GetNamespace() != metav1.NamespaceAll {
+		t.Fatalf("listNodeEvents() action = %v, want a list in all namespaces", actions[0])
+	}
+	if got := list.GetListRestrictions().Fields.String(); got != "involvedObject.kind=Node,involvedObject.name=node-1" {
+		t.Errorf("listNodeEvents() field selector = %q", got)
+	}
+}
+
+func TestListPodEvents(t *testing.T) {
+	now := time.Now()
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-0", UID: "uid-2"}}
+	podEvent := func(name, uid, eventType, reason, message string, lastTimestamp time.Time) *v1.Event {
+		return &v1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Namespace: "default", Name: name},
+			InvolvedObject: v1.ObjectReference{Kind: "Pod", Name: "web-0", UID: types.UID(uid)},
+			Type:           eventType,
+			Reason:         reason,
+			Message:        message,
+			LastTimestamp:  metav1.NewTime(lastTimestamp),
+		}
+	}
+	clientset := fake.NewSimpleClientset(
+		podEvent("killing", "uid-2", v1.EventTypeNormal, "Killing", "Container app failed liveness probe, will be restarted", now.Add(-time.Minute)),
+		podEvent("unhealthy", "uid-2", v1.EventTypeWarning, "Unhealthy", "Liveness probe failed: timeout", now.Add(-2*time.Minute)),
+		podEvent("pulled", "uid-2", v1.EventTypeNormal, "Pulled", "Container image already present", now.Add(-time.Minute)),
+		podEvent("expired", "uid-2", v1.EventTypeWarning, "BackOff", "Back-off restarting failed container", now.Add(-2*time.Hour)),
+		// The previous pod of the StatefulSet with the same name
+		podEvent("previous", "uid-1", v1.EventTypeWarning, "FailedMount", "MountVolume.SetUp failed", now.Add(-time.Minute)),
+	)
+	filterEventsByUID(clientset)
+	c := &Controller{clientset: clientset, eventsWindow: time.Hour}
+
+	events, err := c.listPodEvents(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reasons []string
+	for _, event := range events {
+		reasons = append(reasons, event.Reason)
+	}
+	// The relevant events of the pod UID within the window, sorted by their last timestamp
+	want := []string{"Unhealthy", "Killing"}
+	if !reflect.DeepEqual(reasons, want) {
+		t.Errorf("listPodEvents() reasons = %v, want %v", reasons, want)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubectl/pkg/describe"
+)
+
+// DiagnosisRule maps a container termination to a human-readable explanation and suggested next steps.
+// A rule matches when all of its set criteria match, a rule without criteria never matches.
+type DiagnosisRule struct {
+	Name        string   `json:"name"`
+	ExitCodes   []int32  `json:"exitCodes,omitempty"`  // Any of the exit codes
+	Signals     []int32  `json:"signals,omitempty"`    // Any of the signals, from the signal or an exit code > 128
+	Reasons     []string `json:"reasons,omitempty"`    // Any of the termination reasons, e.g. OOMKilled, ContainerCannotRun
+	LogPattern  string   `json:"logPattern,omitempty"` // A regular expression matching the logs before restart
+	Explanation string   `json:"explanation"`
+	NextSteps   []string `json:"nextSteps,omitempty"`
+
+	logRegexp *regexp.Regexp
+}
+
+// DiagnosisCatalog is the ordered list of diagnosis rules.
+type DiagnosisCatalog []DiagnosisRule
+
+var signalNames = map[int32]string{
+	1:  "SIGHUP",
+	2:  "SIGINT",
+	3:  "SIGQUIT",
+	4:  "SIGILL",
+	6:  "SIGABRT",
+	7:  "SIGBUS",
+	8:  "SIGFPE",
+	9:  "SIGKILL",
+	11: "SIGSEGV",
+	13: "SIGPIPE",
+	15: "SIGTERM",
+}
+
+var builtinDiagnoses = []DiagnosisRule{
+	{
+		Name:        "OOMKilled",
+		Reasons:     []string{"OOMKilled"},
+		Explanation: "The container was killed by the kernel OOM killer because it used more memory than its limit, or the node ran out of memory.",
+		NextSteps: []string{
+			"Compare the memory usage with the container memory limit and raise the limit if needed",
+			"Check the application for memory leaks or unbounded caches",
+			"Check the node for MemoryPressure if the container has no memory limit",
+		},
+	},
+	{
+		Name:        "ContainerCannotRun",
+		Reasons:     []string{"ContainerCannotRun"},
+		Explanation: "The container runtime could not start the container process.",
+		NextSteps: []string{
+			"Check the termination message for the runtime error",
+			"Check the image entrypoint, command, volume mounts and security context",
+		},
+	},
+	{
+		Name:        "SIGKILL",
+		Reasons:     []string{"Error"},
+		Signals:     []int32{9},
+		Explanation: "The container was killed with SIGKILL, usually by the kubelet after a failed liveness probe or an expired termination grace period, or by the OOM killer in a child process.",
+		NextSteps: []string{
+			"Check the Pod events for liveness probe failures",
+			"Check whether the application handles SIGTERM within terminationGracePeriodSeconds",
+		},
+	},
+	{
+		Name:        "SIGSEGV",
+		Signals:     []int32{11},
+		Explanation: "The container process crashed with a segmentation fault, an invalid memory access in native code.",
+		NextSteps: []string{
+			"Check the logs for a native stack trace",
+			"Check for incompatible native libraries in the image, or the image architecture",
+		},
+	},
+	{
+		Name:        "SIGABRT",
+		Signals:     []int32{6},
+		Explanation: "The container process aborted itself, usually after a failed assertion or a fatal runtime error.",
+		NextSteps: []string{
+			"Check the logs for the fatal error before the abort",
+		},
+	},
+	{
+		Name:        "SIGTERM",
+		Signals:     []int32{15},
+		Explanation: "The container process was terminated with SIGTERM and did not exit with 0, e.g. it was stopped by the kubelet and did not shut down gracefully.",
+		NextSteps: []string{
+			"Check the Pod events for the reason of the termination (liveness probe, eviction, preemption)",
+			"Make the application exit with 0 on SIGTERM",
+		},
+	},
+	{
+		Name:        "ApplicationError",
+		ExitCodes:   []int32{1, 2},
+		Explanation: "The application exited with an error.",
+		NextSteps: []string{
+			"Check the logs before restart for the error",
+			"Check recent configuration, secret or dependency changes",
+		},
+	},
+	{
+		Name:        "CommandNotExecutable",
+		ExitCodes:   []int32{126},
+		Explanation: "The container command was found but could not be executed, e.g. missing execute permission.",
+		NextSteps: []string{
+			"Check the file permissions of the entrypoint in the image",
+		},
+	},
+	{
+		Name:        "CommandNotFound",
+		ExitCodes:   []int32{127},
+		Explanation: "The container command was not found in the image.",
+		NextSteps: []string{
+			"Check the command and args of the container, and the PATH of the image",
+		},
+	},
+	{
+		Name:        "GoPanic",
+		LogPattern:  `(?m)^(\S+ )?(panic: |fatal error: )`,
+		Explanation: "The Go application panicked.",
+		NextSteps: []string{
+			"Check the goroutine stack trace in the logs",
+		},
+	},
+	{
+		Name:        "JavaOutOfMemoryError",
+		LogPattern:  `java\.lang\.OutOfMemoryError`,
+		Explanation: "The Java application ran out of heap or metaspace.",
+		NextSteps: []string{
+			"Check the JVM heap settings (-Xmx, -XX:MaxRAMPercentage) against the container memory limit",
+		},
+	},
+	{
+		Name:        "ExecFormatError",
+		LogPattern:  `exec format error`,
+		Explanation: "The container binary was built for another CPU architecture than the node.",
+		NextSteps: []string{
+			"Build a multi-arch image, or schedule the Pod on nodes with the matching architecture",
+		},
+	},
+	{
+		Name:        "AddressInUse",
+		LogPattern:  `(?i)address already in use`,
+		Explanation: "The application could not bind its port because another process in the Pod uses it.",
+		NextSteps: []string{
+			"Check the ports of the other containers in the Pod, and hostNetwork",
+		},
+	},
+	{
+		Name:        "DependencyUnavailable",
+		LogPattern:  `(?i)(connection refused|no such host|i/o timeout)`,
+		Explanation: "The application could not reach a dependency.",
+		NextSteps: []string{
+			"Check the health and DNS name of the dependency",
+			"Make the application retry instead of exiting at startup",
+		},
+	},
+}
+
+// NewDiagnosisCatalog creates the diagnosis catalog with the user rules followed by the built-in rules.
+func NewDiagnosisCatalog(rules []DiagnosisRule) (DiagnosisCatalog, error) {
+	var catalog DiagnosisCatalog
+	for _, rule := range append(append([]DiagnosisRule{}, rules...), builtinDiagnoses...) {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("diagnosis rule name is required")
+		}
+		if rule.LogPattern != "" {
+			logRegexp, err := regexp.Compile(rule.LogPattern)
+			if err != nil {
+				return nil, fmt.Errorf("diagnosis rule %s has invalid logPattern: %v", rule.Name, err)
+			}
+			rule.logRegexp = logRegexp
+		}
+		catalog = append(catalog, rule)
+	}
+	return catalog, nil
+}
+
+// diagnose returns the rules matching the container termination and its logs, in catalog order.
+func (catalog DiagnosisCatalog) diagnose(terminated *v1.ContainerStateTerminated, logs string) []DiagnosisRule {
+	if terminated == nil {
+		return nil
+	}
+
+	var diagnoses []DiagnosisRule
+	for _, rule := range catalog {
+		if rule.matches(terminated, logs) {
+			diagnoses = append(diagnoses, rule)
+		}
+	}
+	return diagnoses
+}
+
+func (rule DiagnosisRule) matches(terminated *v1.ContainerStateTerminated, logs string) bool {
+	if len(rule.ExitCodes) == 0 && len(rule.Signals) == 0 && len(rule.Reasons) == 0 && rule.logRegexp == nil {
+		return false
+	}
+	if len(rule.ExitCodes) > 0 && !containsInt32(rule.ExitCodes, terminated.ExitCode) {
+		return false
+	}
+	if len(rule.Signals) > 0 && !containsInt32(rule.Signals, getTerminationSignal(terminated)) {
+		return false
+	}
+	if len(rule.Reasons) > 0 && !containsString(rule.Reasons, terminated.Reason) {
+		return false
+	}
+	if rule.logRegexp != nil && !rule.logRegexp.MatchString(logs) {
+		return false
+	}
+	return true
+}
+
+// getTerminationSignal returns the signal which terminated the container, 0 if none.
+func getTerminationSignal(terminated *v1.ContainerStateTerminated) int32 {
+	if terminated.Signal != 0 {
+		return terminated.Signal
+	}
+	if terminated.ExitCode > 128 {
+		return terminated.ExitCode - 128
+	}
+	return 0
+}
+
+// printExitCode prints the exit code with the signal name, e.g. 137 (SIGKILL).
+func printExitCode(terminated *v1.ContainerStateTerminated) string {
+	if signal := getTerminationSignal(terminated); signal != 0 {
+		if name, ok := signalNames[signal]; ok {
+			return fmt.Sprintf("%d (%s)", terminated.ExitCode, name)
+		}
+		return fmt.Sprintf("%d (signal %d)", terminated.ExitCode, signal)
+	}
+	return fmt.Sprintf("%d", terminated.ExitCode)
+}
+
+// getDiagnosis returns the diagnosis of the container restart, empty if no rule matches.
+func (c *Controller) getDiagnosis(status v1.ContainerStatus, logs string) (string, error) {
+	terminated := status.LastTerminationState.Terminated
+	diagnoses := c.diagnoses.diagnose(terminated, logs)
+	if len(diagnoses) == 0 {
+		return "", nil
+	}
+	return describeDiagnoses(terminated, diagnoses)
+}
+
+func describeDiagnoses(terminated *v1.ContainerStateTerminated, diagnoses []DiagnosisRule) (string, error) {
+	return tabbedString(func(out io.Writer) error {
+		w := describe.NewPrefixWriter(out)
+		w.Write(describe.LEVEL_0, "Exit Code:\t%s\n", printExitCode(terminated))
+		for _, diagnosis := range diagnoses {
+			w.Write(describe.LEVEL_0, "%s:\t%s\n", diagnosis.Name, diagnosis.Explanation)
+			for _, step := range diagnosis.NextSteps {
+				w.Write(describe.LEVEL_1, "- %s\n", step)
+			}
+		}
+		return nil
+	})
+}
+
+func containsInt32(list []int32, value int32) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
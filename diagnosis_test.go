@@ -0,0 +1,97 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestDiagnose(t *testing.T) {
+	catalog, err := NewDiagnosisCatalog([]DiagnosisRule{
+		{Name: "MigrationFailed", ExitCodes: []int32{1}, LogPattern: "migration .* failed", Explanation: "The migration failed."},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		terminated *v1.ContainerStateTerminated
+		logs       string
+		want       []string
+	}{
+		{
+			name: "not terminated",
+		},
+		{
+			name:       "OOMKilled",
+			terminated: &v1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137},
+			want:       []string{"OOMKilled"},
+		},
+		{
+			name:       "SIGKILL",
+			terminated: &v1.ContainerStateTerminated{Reason: "Error", ExitCode: 137},
+			want:       []string{"SIGKILL"},
+		},
+		{
+			name:       "signal from exit code",
+			terminated: &v1.ContainerStateTerminated{Reason: "Error", ExitCode: 139},
+			want:       []string{"SIGSEGV"},
+		},
+		{
+			name:       "application error",
+			terminated: &v1.ContainerStateTerminated{Reason: "Error", ExitCode: 1},
+			logs:       "starting\n",
+			want:       []string{"ApplicationError"},
+		},
+		{
+			name:       "user rule before the built-in rules",
+			terminated: &v1.ContainerStateTerminated{Reason: "Error", ExitCode: 1},
+			logs:       "migration 42 failed\n",
+			want:       []string{"MigrationFailed", "ApplicationError"},
+		},
+		{
+			name:       "go panic",
+			terminated: &v1.ContainerStateTerminated{Reason: "Error", ExitCode: 2},
+			logs:       "2024-01-01T00:00:00Z panic: runtime error: index out of range\n",
+			want:       []string{"ApplicationError", "GoPanic"},
+		},
+		{
+			name:       "command not found",
+			terminated: &v1.ContainerStateTerminated{Reason: "ContainerCannotRun", ExitCode: 127},
+			want:       []string{"ContainerCannotRun", "CommandNotFound"},
+		},
+		{
+			name:       "completed",
+			terminated: &v1.ContainerStateTerminated{Reason: "Completed", ExitCode: 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, rule := range catalog.diagnose(tt.terminated, tt.logs) {
+				got = append(got, rule.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diagnose() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDiagnosisCatalogErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule DiagnosisRule
+	}{
+		{"missing name", DiagnosisRule{ExitCodes: []int32{1}}},
+		{"invalid log pattern", DiagnosisRule{Name: "Invalid", LogPattern: "("}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewDiagnosisCatalog([]DiagnosisRule{tt.rule}); err == nil {
+				t.Error("NewDiagnosisCatalog() returned no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/klog/v2"
+	"k8s.io/kubectl/pkg/describe"
+)
+
+const (
+	defaultDigestPeriod = 24 * time.Hour
+	defaultDigestTopN   = 5
+	// digestNewOffenderWindow is the time before the digest period without restarts of a new offender.
+	digestNewOffenderWindow = 28 * 24 * time.Hour
+	// digestTrendOffset compares the digest period with the same period a week before.
+	digestTrendOffset = 7 * 24 * time.Hour
+)
+
+// DigestSchedule is a scheduled summary of the incidents routed to a Slack channel.
+type DigestSchedule struct {
+	Channel  string          `json:"channel,omitempty"`  // The Slack channel, default: SLACK_CHANNEL
+	Schedule string          `json:"schedule"`           // Cron expression, e.g. "0 9 * * 1" for Mondays at 09:00
+	Timezone string          `json:"timezone,omitempty"` // IANA time zone of the schedule, default: local time
+	Period   metav1.Duration `json:"period,omitempty"`   // The time covered by the digest, default: 24h
+	TopN     int             `json:"topN,omitempty"`     // The number of workloads and nodes listed, default: 5
+}
+
+// startDigests schedules the digests of the incidents recorded in the incident store.
+func (c *Controller) startDigests(schedules []DigestSchedule) error {
+	if len(schedules) == 0 {
+		return nil
+	}
+	if c.incidents == nil {
+		return fmt.Errorf("digests require the incident store, INCIDENT_DB_PATH is not set")
+	}
+
+	scheduler := cron.New()
+	for _, schedule := range schedules {
+		schedule := schedule
+		if schedule.Channel == "" {
+			schedule.Channel = c.slack.DefaultChannel
+		}
+		if schedule.Period.Duration <= 0 {
+			schedule.Period.Duration = defaultDigestPeriod
+		}
+		if schedule.TopN <= 0 {
+			schedule.TopN = defaultDigestTopN
+		}
+		spec := schedule.spec()
+		_, err := scheduler.AddFunc(spec, func() {
+			if err := c.sendDigest(schedule, time.Now()); err != nil {
+				klog.Errorf("Failed while sending digest to %s: %v", schedule.Channel, err)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("digest of channel %s has invalid schedule %q: %v", schedule.Channel, spec, err)
+		}
+		klog.Infof("Digest: channel: %s, schedule: %s, period: %s\n", schedule.Channel, spec, schedule.Period.Duration)
+	}
+	scheduler.Start()
+	return nil
+}
+
+// spec returns the cron spec of the schedule in its time zone.
+func (schedule DigestSchedule) spec() string {
+	if schedule.Timezone != "" {
+		return fmt.Sprintf("CRON_TZ=%s %s", schedule.Timezone, schedule.Schedule)
+	}
+	return schedule.Schedule
+}
+
+// validateDigestSchedules validates the schedules without starting them.
+func validateDigestSchedules(schedules []DigestSchedule) error {
+	for _, schedule := range schedules {
+		if _, err := cron.ParseStandard(schedule.spec()); err != nil {
+			return fmt.Errorf("digest of channel %s has invalid schedule %q: %v", schedule.Channel, schedule.spec(), err)
+		}
+	}
+	return nil
+}
+
+// sendDigest sends the digest of the incidents routed to the channel during the period before now.
+// The restarts and the trends count the notified incidents, the others are counted by status.
+func (c *Controller) sendDigest(schedule DigestSchedule, now time.Time) error {
+	start := now.Add(-schedule.Period.Duration)
+	trendStart, trendEnd := start.Add(-digestTrendOffset), now.Add(-digestTrendOffset)
+	workloadKey := func(incident IncidentRecord) string {
+		return incident.Namespace + "/" + incident.WorkloadKind + "/" + incident.Workload
+	}
+
+	var current, previous int
+	currentWorkloads, previousWorkloads, historyWorkloads := map[string]int{}, map[string]int{}, map[string]int{}
+	reasons, nodes, notNotified := map[string]int{}, map[string]int{}, map[string]int{}
+	// The incidents are aggregated while reading the store, without loading them all in memory
+	err := c.incidents.forEach(IncidentFilter{
+		Channel: schedule.Channel,
+		Since:   start.Add(-digestNewOffenderWindow),
+		Until:   now,
+	}, func(incident IncidentRecord) {
+		notified := incident.Status == IncidentNotified
+		if !incident.Time.Before(start) {
+			if !notified {
+				notNotified[incident.Status]++
+				return
+			}
+			current++
+			currentWorkloads[workloadKey(incident)]++
+			reasons[incident.Reason]++
+			nodes[incident.Node]++
+			return
+		}
+		// The workloads which restarted before are not new offenders, even if the restarts were not notified
+		historyWorkloads[workloadKey(incident)]++
+		if notified && !incident.Time.Before(trendStart) && incident.Time.Before(trendEnd) {
+			previous++
+			previousWorkloads[workloadKey(incident)]++
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("• Restarts: `%d`, week over week: `%s`\n", current, printTrend(current, previous))
+	if len(notNotified) > 0 {
+		var statuses []string
+		for _, item := range topCounts(notNotified, len(notNotified)) {
+			statuses = append(statuses, fmt.Sprintf("%s `%d`", item.Key, item.Count))
+		}
+		text += fmt.Sprintf("• Not Notified: %s\n", strings.Join(statuses, ", "))
+	}
+	if current > 0 {
+		topWorkloads, err := tabbedString(func(out io.Writer) error {
+			w := describe.NewPrefixWriter(out)
+			w.Write(describe.LEVEL_0, "WORKLOAD\tRESTARTS\tWEEK OVER WEEK\n")
+			for _, item := range topCounts(currentWorkloads, schedule.TopN) {
+				w.Write(describe.LEVEL_0, "%s\t%d\t%s\n", item.Key, item.Count, printTrend(item.Count, previousWorkloads[item.Key]))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		reasonsBreakdown, err := printCounts("REASON", topCounts(reasons, len(reasons)))
+		if err != nil {
+			return err
+		}
+		noisiestNodes, err := printCounts("NODE", topCounts(nodes, schedule.TopN))
+		if err != nil {
+			return err
+		}
+
+		var newOffenders, recurringOffenders []string
+		for _, item := range topCounts(currentWorkloads, len(currentWorkloads)) {
+			if historyWorkloads[item.Key] == 0 {
+				newOffenders = append(newOffenders, fmt.Sprintf("`%s`", item.Key))
+			} else {
+				recurringOffenders = append(recurringOffenders, fmt.Sprintf("`%s`", item.Key))
+			}
+		}
+
+		text += fmt.Sprintf("• Top Workloads\n```\n%s```\n", topWorkloads) +
+			fmt.Sprintf("• Reasons\n```\n%s```\n", reasonsBreakdown) +
+			fmt.Sprintf("• New Offenders (no restarts in the %s before): %s\n", duration.HumanDuration(digestNewOffenderWindow), printOffenders(newOffenders, schedule.TopN)) +
+			fmt.Sprintf("• Recurring Offenders: %s\n", printOffenders(recurringOffenders, schedule.TopN)) +
+			fmt.Sprintf("• Noisiest Nodes\n```\n%s```\n", noisiestNodes)
+	}
+
+	msg := SlackMessage{
+		Title:  fmt.Sprintf("*Restart digest*\n*cluster: `%s`, period: last `%s`*", c.slack.ClusterName, duration.HumanDuration(schedule.Period.Duration)),
+		Text:   text,
+		Footer: fmt.Sprintf("%s, %s - %s", c.slack.ClusterName, start.Format(time.RFC1123Z), now.Format(time.RFC1123Z)),
+	}
+	return c.slack.sendToChannel(msg, schedule.Channel)
+}
+
+// topCounts returns the n keys with the most counts.
+func topCounts(counts map[string]int, n int) []IncidentCount {
+	top := make([]IncidentCount, 0, len(counts))
+	for key, count := range counts {
+		top = append(top, IncidentCount{Key: key, Count: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Key < top[j].Key
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+func printCounts(name string, counts []IncidentCount) (string, error) {
+	return tabbedString(func(out io.Writer) error {
+		w := describe.NewPrefixWriter(out)
+		w.Write(describe.LEVEL_0, "%s\tRESTARTS\n", name)
+		for _, item := range counts {
+			key := item.Key
+			if key == "" {
+				key = "<none>"
+			}
+			w.Write(describe.LEVEL_0, "%s\t%d\n", key, item.Count)
+		}
+		return nil
+	})
+}
+
+// printTrend prints the change from the previous count, e.g. "+3 (+50%)".
+func printTrend(current, previous int) string {
+	if previous == 0 {
+		if current == 0 {
+			return "0"
+		}
+		return fmt.Sprintf("+%d (new)", current)
+	}
+	return fmt.Sprintf("%+d (%+d%%)", current-previous, (current-previous)*100/previous)
+}
+
+// printOffenders prints the first n workloads, followed by the number of the others.
+func printOffenders(workloads []string, n int) string {
+	if len(workloads) == 0 {
+		return "none"
+	}
+	if len(workloads) <= n {
+		return strings.Join(workloads, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(workloads[:n], ", "), len(workloads)-n)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSendDigest(t *testing.T) {
+	store := newTestIncidentStore(t)
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)
+	for _, incident := range []IncidentRecord{
+		{Channel: "ops", Namespace: "payments", WorkloadKind: "Deployment", Workload: "api", Reason: "OOMKilled", Node: "node-1", Status: IncidentNotified, Time: now.Add(-time.Hour)},
+		{Channel: "ops", Namespace: "payments", WorkloadKind: "Deployment", Workload: "api", Reason: "OOMKilled", Node: "node-1", Status: IncidentNotified, Time: now.Add(-2 * time.Hour)},
+		{Channel: "ops", Namespace: "web", WorkloadKind: "StatefulSet", Workload: "web", Reason: "Error", Node: "node-2", Status: IncidentNotified, Time: now.Add(-3 * time.Hour)},
+		// Counted apart from the restarts
+		{Channel: "ops", Namespace: "payments", WorkloadKind: "Deployment", Workload: "api", Reason: "OOMKilled", Node: "node-1", Status: IncidentMuted, Time: now.Add(-30 * time.Minute)},
+		// The same period a week before, web is a recurring offender
+		{Channel: "ops", Namespace: "web", WorkloadKind: "StatefulSet", Workload: "web", Reason: "Error", Node: "node-2", Status: IncidentNotified, Time: now.Add(-digestTrendOffset - time.Hour)},
+		// Routed to another channel
+		{Channel: "web", Namespace: "web", WorkloadKind: "StatefulSet", Workload: "web", Reason: "Error", Node: "node-2", Status: IncidentNotified, Time: now.Add(-time.Hour)},
+	} {
+		incident := incident
+		if err := store.Add(&incident); err != nil {
+			t.Fatal(err)
+		}
+	}
+	slack, webhook := newTestSlack(t, http.StatusOK)
+	c := &Controller{slack: slack, incidents: store}
+
+	schedule := DigestSchedule{Channel: "ops", Schedule: "0 9 * * *", Period: metav1.Duration{Duration: 24 * time.Hour}, TopN: 5}
+	if err := c.sendDigest(schedule, now); err != nil {
+		t.Fatal(err)
+	}
+	sent := webhook.sent()
+	if len(sent) != 1 {
+		t.Fatalf("sendDigest() sent %d messages, want 1", len(sent))
+	}
+	if want := "*Restart digest*\n*cluster: `test`, period: last `24h`*"; sent[0].Title != want {
+		t.Errorf("sendDigest() title = %q, want %q", sent[0].Title, want)
+	}
+	for _, want := range []string{
+		"• Restarts: `3`, week over week: `+2 (+200%)`\n",
+		"• Not Notified: muted `1`\n",
+		"payments/Deployment/api  2         +2 (new)",
+		"web/StatefulSet/web      1         +0 (+0%)",
+		"OOMKilled  2",
+		"• New Offenders (no restarts in the 28d before): `payments/Deployment/api`\n",
+		"• Recurring Offenders: `web/StatefulSet/web`\n",
+		"node-1  2",
+	} {
+		if !strings.Contains(sent[0].Text, want) {
+			t.Errorf("sendDigest() text = %q, want %q", sent[0].Text, want)
+		}
+	}
+}
+
+func TestPrintTrend(t *testing.T) {
+	tests := []struct {
+		current, previous int
+		want              string
+	}{
+		{0, 0, "0"},
+		{3, 0, "+3 (new)"},
+		{3, 2, "+1 (+50%)"},
+		{1, 4, "-3 (-75%)"},
+	}
+	for _, tt := range tests {
+		if got := printTrend(tt.current, tt.previous); got != tt.want {
+			t.Errorf("printTrend(%d, %d) = %q, want %q", tt.current, tt.previous, got, tt.want)
+		}
+	}
+}
+
+func TestPrintOffenders(t *testing.T) {
+	workloads := []string{"`a`", "`b`", "`c`"}
+	if got := printOffenders(workloads, 2); got != "`a`, `b` and 1 more" {
+		t.Errorf("printOffenders() = %q", got)
+	}
+	if got := printOffenders(nil, 2); got != "none" {
+		t.Errorf("printOffenders(nil) = %q", got)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// DryRun logs the messages instead of sending them, and appends them to a JSON Lines file if set.
+type DryRun struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// dryRunRecord is a message which would be sent, a line of the dry run file.
+type dryRunRecord struct {
+	Time    time.Time     `json:"time"`
+	Channel string        `json:"channel"`
+	Thread  string        `json:"thread,omitempty"` // The timestamp of the replied message in bot mode
+	Title   string        `json:"title"`
+	Text    string        `json:"text"`
+	Footer  string        `json:"footer"`
+	Color   string        `json:"color,omitempty"`
+	Actions []SlackAction `json:"actions,omitempty"`
+}
+
+// NewDryRun creates the DryRun if DRY_RUN is true, nil otherwise.
+func NewDryRun() (*DryRun, error) {
+	if os.Getenv("DRY_RUN") != "true" {
+		return nil, nil
+	}
+	dryRun := &DryRun{}
+	if path := os.Getenv("DRY_RUN_FILE"); path != "" {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("got error while opening dry run file %s: %v", path, err)
+		}
+		dryRun.file = file
+	}
+	klog.Warningf("Dry run: the messages are logged instead of being sent, file: %s\n", os.Getenv("DRY_RUN_FILE"))
+	return dryRun, nil
+}
+
+// record logs the message which would be sent to the channel, or as a reply of the thread.
+func (dryRun *DryRun) record(msg SlackMessage, channel string, thread string) {
+	klog.InfoS("Dry run: message not sent", "channel", channel, "thread", thread,
+		"title", msg.Title, "text", msg.Text, "footer", msg.Footer)
+	if dryRun.file == nil {
+		return
+	}
+
+	line, err := json.Marshal(dryRunRecord{
+		Time:    time.Now(),
+		Channel: channel,
+		Thread:  thread,
+		Title:   msg.Title,
+		Text:    msg.Text,
+		Footer:  msg.Footer,
+		Color:   msg.Color,
+		Actions: msg.Actions,
+	})
+	if err != nil {
+		klog.Errorf("Failed while marshaling dry run message: %v", err)
+		return
+	}
+	dryRun.mu.Lock()
+	defer dryRun.mu.Unlock()
+	if _, err := dryRun.file.Write(append(line, '\n')); err != nil {
+		klog.Errorf("Failed while writing dry run file: %v", err)
+	}
+}
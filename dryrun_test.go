@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readDryRunFile returns the records of the dry run file.
+func readDryRunFile(t *testing.T, path string) []dryRunRecord {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var records []dryRunRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record dryRunRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("invalid dry run line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestNewDryRun(t *testing.T) {
+	os.Unsetenv("DRY_RUN")
+	if dryRun, err := NewDryRun(); err != nil || dryRun != nil {
+		t.Errorf("NewDryRun() = %v, %v, want disabled by default", dryRun, err)
+	}
+
+	os.Setenv("DRY_RUN", "true")
+	defer os.Unsetenv("DRY_RUN")
+	os.Setenv("DRY_RUN_FILE", filepath.Join(t.TempDir(), "missing", "dry-run.jsonl"))
+	defer os.Unsetenv("DRY_RUN_FILE")
+	if _, err := NewDryRun(); err == nil {
+		t.Errorf("NewDryRun() opened a file in a missing directory")
+	}
+}
+
+func TestDryRunSlack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dry-run.jsonl")
+	os.Setenv("DRY_RUN", "true")
+	defer os.Unsetenv("DRY_RUN")
+	os.Setenv("DRY_RUN_FILE", path)
+	defer os.Unsetenv("DRY_RUN_FILE")
+	dryRun, err := NewDryRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dryRun.file.Close()
+	slack, webhook := newTestSlack(t, http.StatusOK)
+	slack.dryRun = dryRun
+
+	if err := slack.sendToChannel(SlackMessage{Title: "*Pod restarted!*", Text: "logs", Footer: "test"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	thread, err := slack.sendToThread(SlackMessage{Title: "*Pod recovered!*", Color: "#2EB67D"}, SlackThread{Channel: "ops", Timestamp: "1700000000.000100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thread.Channel != "ops" {
+		t.Errorf("sendToThread() thread = %v, want the ops channel", thread)
+	}
+	if err := slack.respond("https://hooks.slack.com/commands/secret", SlackMessage{Title: "*Pod report*"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := slack.uploadToThread("web-0.yaml", "Pod YAML", "kind: Pod", SlackThread{Channel: "ops", Timestamp: "1700000000.000100"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if sent := webhook.sent(); len(sent) != 0 {
+		t.Errorf("dry run sent %d messages to Slack", len(sent))
+	}
+	records := readDryRunFile(t, path)
+	want := []dryRunRecord{
+		{Channel: "restart-info", Title: "*Pod restarted!*", Text: "logs", Footer: "test"},
+		{Channel: "ops", Thread: "1700000000.000100", Title: "*Pod recovered!*", Color: "#2EB67D"},
+		// The response URL is a secret, it is not recorded
+		{Channel: "response:in_channel", Title: "*Pod report*"},
+		{Channel: "ops", Thread: "1700000000.000100", Title: "Pod YAML", Text: "kind: Pod", Footer: "web-0.yaml"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("dry run recorded %d messages, want %d: %v", len(records), len(want), records)
+	}
+	for i, record := range records {
+		if record.Time.IsZero() {
+			t.Errorf("record %d has no time", i)
+		}
+		if record.Channel != want[i].Channel || record.Thread != want[i].Thread || record.Title != want[i].Title ||
+			record.Text != want[i].Text || record.Footer != want[i].Footer || record.Color != want[i].Color {
+			t.Errorf("record %d = %+v, want %+v", i, record, want[i])
+		}
+	}
+}
@@ -3,12 +3,16 @@ module kubecollect
 go 1.16
 
 require (
-	github.com/slack-go/slack v0.10.0 // indirect
+	github.com/minio/minio-go/v7 v7.0.23
+	github.com/robfig/cron/v3 v3.0.1
+	github.com/slack-go/slack v0.10.0
+	go.etcd.io/bbolt v1.3.6
 	golang.org/x/oauth2 v0.0.0-20210819190943-2bc19b11175f // indirect
 	k8s.io/api v0.23.0
 	k8s.io/apimachinery v0.23.0
 	k8s.io/client-go v0.23.0
 	k8s.io/klog/v2 v2.30.0
-	k8s.io/kubectl v0.23.0 // indirect
-	k8s.io/utils v0.0.0-20210930125809-cb0fa318a74b // indirect
+	k8s.io/kubectl v0.23.0
+	k8s.io/utils v0.0.0-20210930125809-cb0fa318a74b
+	sigs.k8s.io/yaml v1.2.0
 )
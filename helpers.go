@@ -13,10 +13,12 @@ import (
 	"time"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/duration"
 	"k8s.io/klog/v2"
 	"k8s.io/kubectl/pkg/describe"
+	resourcehelper "k8s.io/kubectl/pkg/util/resource"
 )
 
 func getPodRestartCount(pod *v1.Pod) int {
@@ -114,6 +116,33 @@ func getIgnoreRestartCount() int {
 	return ignoreRestartCount
 }
 
+func getNodeIncidentPodThreshold() int {
+	nodeIncidentPodThreshold, err := strconv.Atoi(os.Getenv("NODE_INCIDENT_POD_THRESHOLD"))
+	if err != nil {
+		nodeIncidentPodThreshold = 0
+		klog.Warningf("Environment variable NODE_INCIDENT_POD_THRESHOLD is not set, default: %d\n", nodeIncidentPodThreshold)
+	}
+	return nodeIncidentPodThreshold
+}
+
+func getNodeIncidentWindow() time.Duration {
+	nodeIncidentWindowSeconds, err := strconv.Atoi(os.Getenv("NODE_INCIDENT_WINDOW_SECONDS"))
+	if err != nil {
+		nodeIncidentWindowSeconds = 300
+		klog.Warningf("Environment variable NODE_INCIDENT_WINDOW_SECONDS is not set, default: %d\n", nodeIncidentWindowSeconds)
+	}
+	return time.Duration(nodeIncidentWindowSeconds) * time.Second
+}
+
+func getEventsWindow() time.Duration {
+	eventsWindowSeconds, err := strconv.Atoi(os.Getenv("EVENTS_WINDOW_SECONDS"))
+	if err != nil {
+		eventsWindowSeconds = 3600
+		klog.Warningf("Environment variable EVENTS_WINDOW_SECONDS is not set, default: %d\n", eventsWindowSeconds)
+	}
+	return time.Duration(eventsWindowSeconds) * time.Second
+}
+
 func printPod(pod *v1.Pod) (string, error) {
 	restarts := 0
 	totalContainers := len(pod.Spec.Containers)
@@ -217,31 +246,41 @@ func printPod(pod *v1.Pod) (string, error) {
 	})
 }
 
-func printContainerLastStateReason(status v1.ContainerStatus) string {
-	var lastStateReason string
-	var lastExitCode int32
-	if status.LastTerminationState.Terminated != nil {
-		lastStateReason = status.LastTerminationState.Terminated.Reason
-		lastExitCode = status.LastTerminationState.Terminated.ExitCode
+// describeProbeFailures returns the verdict when the container was restarted after probe failures,
+// and the probe configuration and failures, empty if the probes did not fail. The events are the already
+// listed pod events.
+func describeProbeFailures(status v1.ContainerStatus, container v1.Container, events []v1.Event) (verdict string, probes string, err error) {
+	failures, killedBy := getProbeFailures(status, events)
+	if killedBy != "" {
+		verdict = printProbeVerdict(killedBy, container, status.LastTerminationState.Terminated, failures)
 	}
-	return fmt.Sprintf("%s (ExitCode %d)", lastStateReason, lastExitCode)
+	if len(failures) > 0 {
+		probes, err = describeProbes(container, failures)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return verdict, probes, nil
 }
 
-func printNode(obj *v1.Node) (string, error) {
-	conditionMap := make(map[v1.NodeConditionType]*v1.NodeCondition)
-	NodeAllConditions := []v1.NodeConditionType{v1.NodeReady}
-	for i := range obj.Status.Conditions {
-		cond := obj.Status.Conditions[i]
-		conditionMap[cond.Type] = &cond
-	}
+// printNode prints the node status, its non-nominal conditions, and its allocatable resources
+// compared with the requests and limits of the given pods scheduled on it.
+func printNode(obj *v1.Node, pods []*v1.Pod) (string, error) {
 	var status []string
-	for _, validCondition := range NodeAllConditions {
-		if condition, ok := conditionMap[validCondition]; ok {
+	var conditions []v1.NodeCondition
+	for _, condition := range obj.Status.Conditions {
+		if condition.Type == v1.NodeReady {
 			if condition.Status == v1.ConditionTrue {
-				status = append(status, string(condition.Type))
+				status = append([]string{string(condition.Type)}, status...)
 			} else {
-				status = append(status, "Not"+string(condition.Type))
+				status = append([]string{"Not" + string(condition.Type)}, status...)
+			}
+		}
+		if !isNominalNodeCondition(condition) {
+			if condition.Type != v1.NodeReady && condition.Status == v1.ConditionTrue {
+				status = append(status, string(condition.Type))
 			}
+			conditions = append(conditions, condition)
 		}
 	}
 	if len(status) == 0 {
@@ -255,6 +294,93 @@ func printNode(obj *v1.Node) (string, error) {
 		w := describe.NewPrefixWriter(out)
 		w.Write(describe.LEVEL_0, "NAME\tSTATUS\tAGE\tVERSION\n")
 		w.Write(describe.LEVEL_0, "%s\t%s\t%s\t%s\n", obj.Name, strings.Join(status, ","), translateTimestampSince(obj.CreationTimestamp), obj.Status.NodeInfo.KubeletVersion)
+		if len(conditions) > 0 {
+			w.Write(describe.LEVEL_0, "Conditions:\n")
+			for _, c := range conditions {
+				w.Write(describe.LEVEL_1, "%v:\t%v\t%s\t%s\t%s\n",
+					c.Type,
+					c.Status,
+					c.Reason,
+					c.LastTransitionTime.Time.Format(time.RFC1123Z),
+					c.Message)
+			}
+		}
+		describeNodeResources(obj, pods, w)
+		return nil
+	})
+}
+
+// isNominalNodeCondition returns true if the node condition is healthy,
+// the Ready condition is nominal when True, all others (MemoryPressure, DiskPressure, ...) when False.
+func isNominalNodeCondition(condition v1.NodeCondition) bool {
+	if condition.Type == v1.NodeReady {
+		return condition.Status == v1.ConditionTrue
+	}
+	return condition.Status == v1.ConditionFalse
+}
+
+// describeNodeResources writes the node allocatable resources and the sum of requests and limits of the pods.
+func describeNodeResources(node *v1.Node, pods []*v1.Pod, w describe.PrefixWriter) {
+	reqs, limits := v1.ResourceList{}, v1.ResourceList{}
+	podCount := 0
+	for _, pod := range pods {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		podCount++
+		podReqs, podLimits := resourcehelper.PodRequestsAndLimits(pod)
+		for name, quantity := range podReqs {
+			value := reqs[name]
+			value.Add(quantity)
+			reqs[name] = value
+		}
+		for name, quantity := range podLimits {
+			value := limits[name]
+			value.Add(quantity)
+			limits[name] = value
+		}
+	}
+
+	allocatable := node.Status.Allocatable
+	w.Write(describe.LEVEL_0, "Allocated resources:\n")
+	w.Write(describe.LEVEL_1, "Resource\tAllocatable\tRequests\tLimits\n")
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory, v1.ResourceEphemeralStorage} {
+		alloc, ok := allocatable[name]
+		if !ok {
+			continue
+		}
+		req, limit := reqs[name], limits[name]
+		w.Write(describe.LEVEL_1, "%s\t%s\t%s (%d%%)\t%s (%d%%)\n",
+			name, alloc.String(),
+			req.String(), getQuantityPercentage(req, alloc),
+			limit.String(), getQuantityPercentage(limit, alloc))
+	}
+	if allocatablePods, ok := allocatable[v1.ResourcePods]; ok {
+		w.Write(describe.LEVEL_1, "%s\t%s\t%d\t\n", v1.ResourcePods, allocatablePods.String(), podCount)
+	}
+}
+
+// getQuantityPercentage returns the quantity in percent of the total, computed in float64 as the milli-units
+// of large quantities, e.g. TiB of ephemeral storage, overflow int64 when multiplied.
+func getQuantityPercentage(quantity, total resource.Quantity) int64 {
+	if total.IsZero() {
+		return 0
+	}
+	return int64(quantity.AsApproximateFloat64() * 100 / total.AsApproximateFloat64())
+}
+
+func describeNodeConditions(node *v1.Node) (string, error) {
+	return tabbedString(func(out io.Writer) error {
+		w := describe.NewPrefixWriter(out)
+		w.Write(describe.LEVEL_0, "TYPE\tSTATUS\tREASON\tLAST TRANSITION\tMESSAGE\n")
+		for _, c := range node.Status.Conditions {
+			w.Write(describe.LEVEL_0, "%v\t%v\t%s\t%s\t%s\n",
+				c.Type,
+				c.Status,
+				c.Reason,
+				c.LastTransitionTime.Time.Format(time.RFC1123Z),
+				c.Message)
+		}
 		return nil
 	})
 }
@@ -360,6 +486,20 @@ func (o byLastTimestamp) Less(i, j int) bool {
 	return o[i].LastTimestamp.Before(&o[j].LastTimestamp)
 }
 
+// getEventLastTimestamp returns the last time the event was observed, events recorded with the
+// events.k8s.io API only have the EventTime set, or the Series of the repeated events.
+func getEventLastTimestamp(event v1.Event) metav1.Time {
+	switch {
+	case event.Series != nil && !event.Series.LastObservedTime.IsZero():
+		return metav1.NewTime(event.Series.LastObservedTime.Time)
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp
+	case !event.EventTime.IsZero():
+		return metav1.NewTime(event.EventTime.Time)
+	}
+	return event.FirstTimestamp
+}
+
 func getContainerResource(container v1.Container) (string, error) {
 	return tabbedString(func(out io.Writer) error {
 		w := describe.NewPrefixWriter(out)
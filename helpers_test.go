@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetQuantityPercentage(t *testing.T) {
+	tests := []struct {
+		name     string
+		quantity string
+		total    string
+		want     int64
+	}{
+		{"cpu", "500m", "2", 25},
+		{"memory", "3Gi", "4Gi", 75},
+		{"zero total", "1Gi", "0", 0},
+		{"over the total", "3", "2", 150},
+		// The milli-units of 20Ti overflow int64 when multiplied by 100
+		{"large ephemeral storage", "15Ti", "20Ti", 75},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getQuantityPercentage(resource.MustParse(tt.quantity), resource.MustParse(tt.total))
+			if got != tt.want {
+				t.Errorf("getQuantityPercentage(%s, %s) = %d, want %d", tt.quantity, tt.total, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintNode(t *testing.T) {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Status: v1.NodeStatus{
+			Conditions: []v1.NodeCondition{
+				{Type: v1.NodeReady, Status: v1.ConditionTrue},
+				{Type: v1.NodeMemoryPressure, Status: v1.ConditionTrue, Reason: "KubeletHasInsufficientMemory", Message: "kubelet has insufficient memory available"},
+				{Type: v1.NodeDiskPressure, Status: v1.ConditionFalse},
+			},
+			Allocatable: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse("2"),
+				v1.ResourceMemory: resource.MustParse("4Gi"),
+				v1.ResourcePods:   resource.MustParse("110"),
+			},
+		},
+	}
+	pod := &v1.Pod{
+		Spec: v1.PodSpec{Containers: []v1.Container{{
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("500m"), v1.ResourceMemory: resource.MustParse("1Gi")},
+				Limits:   v1.ResourceList{v1.ResourceMemory: resource.MustParse("2Gi")},
+			},
+		}}},
+		Status: v1.PodStatus{Phase: v1.PodRunning},
+	}
+	// The finished pods do not use the node resources
+	finished := pod.DeepCopy()
+	finished.Status.Phase = v1.PodSucceeded
+
+	out, err := printNode(node, []*v1.Pod{pod, finished})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The columns are compared without their padding
+	fields := strings.Join(strings.Fields(out), " ")
+	for _, want := range []string{
+		"node-1 Ready,MemoryPressure",
+		"MemoryPressure: True KubeletHasInsufficientMemory",
+		"kubelet has insufficient memory available",
+		"cpu 2 500m (25%) 0 (0%)",
+		"memory 4Gi 1Gi (25%) 2Gi (50%)",
+		"pods 110 1",
+	} {
+		if !strings.Contains(fields, want) {
+			t.Errorf("printNode() = %q, want it to contain %q", out, want)
+		}
+	}
+	// The nominal conditions are not shown
+	if strings.Contains(out, "DiskPressure") {
+		t.Errorf("printNode() = %q, want no nominal DiskPressure condition", out)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// Incident is the context collected for a container, a failed Job or a node incident, or the report of an expired
+// silence or of a closed maintenance window, rendered per destination format by the renderers.
+// The descriptions are plain text tables like kubectl describe.
+type Incident struct {
+	Title        string            `json:"title"` // e.g. Pod restarted!
+	Cluster      string            `json:"cluster"`
+	Namespace    string            `json:"namespace"`
+	Pod          string            `json:"pod"`
+	Container    string            `json:"container"`
+	Owner        IncidentOwner     `json:"owner"`
+	Labels       map[string]string `json:"labels,omitempty"` // The pod labels
+	Node         string            `json:"node"`
+	Image        string            `json:"image"`
+	RestartCount int32             `json:"restartCount"`
+	// Restarted is whether the container has a last termination, the logs are the logs before the restart
+	Restarted bool   `json:"restarted"`
+	Reason    string `json:"reason,omitempty"`
+	ExitCode  int32  `json:"exitCode"`
+	// RunDuration is how long the terminated container ran before the restart
+	RunDuration time.Duration `json:"runDuration,omitempty"`
+	// ProbeVerdict and Probes explain the restarts after probe failures
+	ProbeVerdict string `json:"probeVerdict,omitempty"`
+	Probes       string `json:"probes,omitempty"`
+	Diagnosis    string `json:"diagnosis,omitempty"`
+	MemoryUsage  string `json:"memoryUsage,omitempty"` // The memory usage of OOMKilled containers
+	// PodStatus is the pod printed like kubectl get pod
+	PodStatus      string          `json:"podStatus"`
+	ContainerState string          `json:"containerState"`
+	Resources      string          `json:"resources"`
+	Events         []IncidentEvent `json:"events"`
+	NodeStatus     string          `json:"nodeStatus"`
+	NodeEvents     []IncidentEvent `json:"nodeEvents"`
+	LogErrors      string          `json:"logErrors,omitempty"`
+	Logs           string          `json:"logs"`
+	SiblingLogs    []ContainerLogs `json:"siblingLogs,omitempty"`
+	Redactions     int             `json:"redactions"` // The number of redacted secrets or personal data
+	// Job is set for the failed Jobs, the pod and container fields are empty
+	Job *IncidentJob `json:"job,omitempty"`
+	// NodeIncident is set for the node incidents, with the node fields
+	NodeIncident *IncidentNode `json:"nodeIncident,omitempty"`
+	// Silence is set for the expired RestartSilences
+	Silence *IncidentSilence `json:"silence,omitempty"`
+	// Maintenance is set for the closed maintenance windows
+	Maintenance *IncidentMaintenanceWindow `json:"maintenance,omitempty"`
+}
+
+// IncidentJob is the context of a failed Job.
+type IncidentJob struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // The Job printed like kubectl get job
+	// CronJob is the schedule and last successful run of the CronJob owning the Job, if any
+	CronJob    string           `json:"cronJob,omitempty"`
+	FailedPods []IncidentJobPod `json:"failedPods"` // The most recent failed pods
+}
+
+// IncidentJobPod is a failed pod of a Job, with the logs of its failed containers.
+type IncidentJobPod struct {
+	Name       string          `json:"name"`
+	Status     string          `json:"status"` // The pod and its failed container states
+	Events     []IncidentEvent `json:"events"`
+	Containers []ContainerLogs `json:"containers"`
+}
+
+// IncidentNode is the context of the pod restarts correlated on a node.
+type IncidentNode struct {
+	RestartedPods int    `json:"restartedPods"`
+	Conditions    string `json:"conditions"`   // All the node conditions
+	AffectedPods  string `json:"affectedPods"` // The restarted pods with their last restart
+}
+
+// IncidentSilence is the report of an expired RestartSilence.
+type IncidentSilence struct {
+	Name       string    `json:"name"`
+	Suppressed int       `json:"suppressed"` // The number of suppressed alerts
+	Matchers   string    `json:"matchers"`
+	StartsAt   time.Time `json:"startsAt"`
+	EndsAt     time.Time `json:"endsAt"`
+	CreatedBy  string    `json:"createdBy,omitempty"`
+	Comment    string    `json:"comment,omitempty"`
+}
+
+// IncidentMaintenanceWindow is the report of the restarts which were not notified during a maintenance window.
+type IncidentMaintenanceWindow struct {
+	Window       string    `json:"window"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	Restarts     int       `json:"restarts"`
+	TopWorkloads string    `json:"topWorkloads"`
+	Reasons      string    `json:"reasons"`
+}
+
+// IncidentOwner is the workload of the pod, e.g. the Deployment.
+type IncidentOwner struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+type IncidentEvent struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Reason  string    `json:"reason"`
+	Message string    `json:"message"`
+}
+
+type ContainerLogs struct {
+	Container string `json:"container"`
+	Errors    string `json:"errors,omitempty"` // The extracted error lines and stack traces
+	Logs      string `json:"logs"`
+}
+
+// collectIncident collects the context of the container, the logs are the logs before the last restart
+// if the container restarted, or the current logs.
+func (c *Controller) collectIncident(pod *v1.Pod, status v1.ContainerStatus) (*Incident, error) {
+	ownerKind, ownerName := getPodWorkload(pod)
+	incident := &Incident{
+		Cluster:      c.slack.ClusterName,
+		Namespace:    pod.Namespace,
+		Pod:          pod.Name,
+		Container:    status.Name,
+		Owner:        IncidentOwner{Kind: ownerKind, Name: ownerName},
+		Labels:       pod.Labels,
+		Node:         pod.Spec.NodeName,
+		Image:        status.Image,
+		RestartCount: status.RestartCount,
+	}
+	if terminated := status.LastTerminationState.Terminated; terminated != nil {
+		incident.Restarted = true
+		incident.Reason = terminated.Reason
+		incident.ExitCode = terminated.ExitCode
+		if !terminated.StartedAt.IsZero() {
+			incident.RunDuration = terminated.FinishedAt.Sub(terminated.StartedAt.Time)
+		}
+	}
+
+	var err error
+	incident.PodStatus, err = printPod(pod)
+	if err != nil {
+		return nil, err
+	}
+	incident.ContainerState, err = describeContainerState(status)
+	if err != nil {
+		return nil, err
+	}
+
+	var containerSpec v1.Container
+	for _, container := range pod.Spec.Containers {
+		if status.Name == container.Name {
+			containerSpec = container
+			break
+		}
+	}
+	incident.Resources, err = getContainerResource(containerSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := c.listPodEvents(pod)
+	if err != nil {
+		return nil, err
+	}
+	incident.Events = newIncidentEvents(events)
+	if incident.Restarted {
+		incident.ProbeVerdict, incident.Probes, err = describeProbeFailures(status, containerSpec, events)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	logs, err := c.getContainerLogs(pod, status, incident.Restarted)
+	if err != nil {
+		return nil, err
+	}
+	incident.Redactions = c.redactor.redactAll(&incident.ContainerState, &incident.ProbeVerdict, &incident.Probes, &logs)
+
+	if incident.Restarted {
+		incident.Diagnosis, err = c.getDiagnosis(status, logs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	incident.MemoryUsage, err = c.getOOMContext(pod, status, containerSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	incident.NodeStatus, incident.NodeEvents, err = c.describeNode(pod)
+	if err != nil {
+		return nil, err
+	}
+	incident.SiblingLogs = c.getSiblingLogs(pod, status)
+	incident.Redactions += c.redactor.redactAll(&incident.NodeStatus)
+	incident.Redactions += c.redactEvents(incident.Events) + c.redactEvents(incident.NodeEvents)
+	for i := range incident.SiblingLogs {
+		incident.Redactions += c.redactor.redactAll(&incident.SiblingLogs[i].Logs)
+	}
+
+	incident.LogErrors = c.logAnalyzer.extractErrors(logs)
+	if logs != "" {
+		incident.Logs = tailLines(logs, c.getLogPolicy(pod).TailLines)
+	}
+	return incident, nil
+}
+
+// describeNode returns the node status and its recent events.
+func (c *Controller) describeNode(pod *v1.Pod) (string, []IncidentEvent, error) {
+	node, err := c.clientset.CoreV1().Nodes().Get(context.TODO(), pod.Spec.NodeName, metav1.GetOptions{})
+	if err != nil {
+		klog.Errorf("Failed while getting the %s Node. Probably was deleted. ", pod.Spec.NodeName)
+		return "", nil, err
+	}
+	status, _ := printNode(node, c.getNodePods(node.Name))
+
+	events, err := c.listNodeEvents(node.Name)
+	if err != nil {
+		return "", nil, err
+	}
+	return status, newIncidentEvents(events), nil
+}
+
+func (c *Controller) redactEvents(events []IncidentEvent) int {
+	var count int
+	for i := range events {
+		count += c.redactor.redactAll(&events[i].Message)
+	}
+	return count
+}
+
+func newIncidentEvents(events []v1.Event) []IncidentEvent {
+	out := make([]IncidentEvent, 0, len(events))
+	for _, event := range events {
+		out = append(out, IncidentEvent{
+			Time:    getEventLastTimestamp(event).Time,
+			Type:    event.Type,
+			Reason:  event.Reason,
+			Message: event.Message,
+		})
+	}
+	return out
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// The action IDs of the alert buttons.
+const (
+	actionMuteWorkload = "mute_workload"
+	actionAcknowledge  = "acknowledge"
+	actionFetchLogs    = "fetch_logs"
+	actionShowPodYAML  = "show_pod_yaml"
+)
+
+const (
+	// workloadMuteDuration is the time a workload is muted by the "Mute workload 1h" button.
+	workloadMuteDuration = time.Hour
+	// fetchLogLines is the number of log lines fetched by the alert button, before the lines of the alert.
+	fetchLogLines = 500
+	// maxInteractionBytes is the size limit of the Slack interaction and slash command requests.
+	maxInteractionBytes = 1 << 20
+)
+
+// SlackAction is a button of the message in interactive mode.
+type SlackAction struct {
+	ID    string `json:"id"`
+	Text  string `json:"text"`
+	Value string `json:"value"`
+}
+
+// alertTarget is the restarted container of the alert, the value of the alert buttons.
+type alertTarget struct {
+	Namespace    string `json:"ns"`
+	Pod          string `json:"pod"`
+	Container    string `json:"c"`
+	WorkloadKind string `json:"wk"`
+	Workload     string `json:"w"`
+}
+
+// WorkloadMutes stores the workloads muted by the alert buttons.
+type WorkloadMutes struct {
+	mu sync.Mutex
+	// until stores the end of the mute, key: Namespace/WorkloadKind/Workload
+	until map[string]time.Time
+}
+
+func NewWorkloadMutes() *WorkloadMutes {
+	return &WorkloadMutes{until: make(map[string]time.Time)}
+}
+
+func (mutes *WorkloadMutes) mute(key string, until time.Time) {
+	mutes.mu.Lock()
+	defer mutes.mu.Unlock()
+	mutes.until[key] = until
+}
+
+// isMuted returns the end of the mute of the workload, and whether it is muted at now.
+func (mutes *WorkloadMutes) isMuted(key string, now time.Time) (time.Time, bool) {
+	mutes.mu.Lock()
+	defer mutes.mu.Unlock()
+	until, ok := mutes.until[key]
+	if !ok {
+		return until, false
+	}
+	if !now.Before(until) {
+		delete(mutes.until, key)
+		return until, false
+	}
+	return until, true
+}
+
+func (target alertTarget) workloadKey() string {
+	return target.Namespace + "/" + target.WorkloadKind + "/" + target.Workload
+}
+
+// getAlertActions returns the buttons of the restarted container alert, nil if interactivity is disabled.
+func (c *Controller) getAlertActions(pod *v1.Pod, status v1.ContainerStatus) []SlackAction {
+	if !c.slack.isInteractive() {
+		return nil
+	}
+	workloadKind, workload := getPodWorkload(pod)
+	value, err := json.Marshal(alertTarget{
+		Namespace:    pod.Namespace,
+		Pod:          pod.Name,
+		Container:    status.Name,
+		WorkloadKind: workloadKind,
+		Workload:     workload,
+	})
+	if err != nil {
+		klog.Errorf("Failed while marshaling %s/%s alert actions: %v", pod.Namespace, pod.Name, err)
+		return nil
+	}
+	return []SlackAction{
+		{ID: actionMuteWorkload, Text: "Mute workload 1h", Value: string(value)},
+		{ID: actionAcknowledge, Text: "Acknowledge", Value: string(value)},
+		{ID: actionFetchLogs, Text: fmt.Sprintf("Fetch %d more log lines", fetchLogLines), Value: string(value)},
+		{ID: actionShowPodYAML, Text: "Show pod YAML", Value: string(value)},
+	}
+}
+
+// handleSlackInteraction handles the clicks on the alert buttons, the requests are verified with the Slack signing secret.
+func (c *Controller) handleSlackInteraction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, fmt.Sprintf("method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	form, ok := c.readSlackRequest(w, r)
+	if !ok {
+		return
+	}
+	var callback slack.InteractionCallback
+	if err := json.Unmarshal([]byte(form.Get("payload")), &callback); err != nil {
+		http.Error(w, fmt.Sprintf("invalid payload: %v", err), http.StatusBadRequest)
+		return
+	}
+	// Slack expects the response within 3 seconds, the actions reply in the alert thread
+	w.WriteHeader(http.StatusOK)
+	if callback.Type != slack.InteractionTypeBlockActions {
+		return
+	}
+	for _, action := range callback.ActionCallback.BlockActions {
+		go c.handleAlertAction(callback, *action)
+	}
+}
+
+// readSlackRequest reads the form of the Slack request verified with the signing secret,
+// the error response is written if it is not valid.
+func (c *Controller) readSlackRequest(w http.ResponseWriter, r *http.Request) (url.Values, bool) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxInteractionBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	verifier, err := slack.NewSecretsVerifier(r.Header, c.slack.SigningSecret)
+	if err != nil {
+		klog.Warningf("Rejected Slack request %s: %v", r.URL.Path, err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return nil, false
+	}
+	verifier.Write(body)
+	if err := verifier.Ensure(); err != nil {
+		klog.Warningf("Rejected Slack request %s: %v", r.URL.Path, err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return nil, false
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return form, true
+}
+
+// handleAlertAction acts on the pod of the alert, and replies in the alert thread.
+// The errors are sent to the user only.
+func (c *Controller) handleAlertAction(callback slack.InteractionCallback, action slack.BlockAction) {
+	user := callback.User.ID
+	thread := SlackThread{Channel: callback.Container.ChannelID, Timestamp: callback.Container.MessageTs}
+	if thread.Channel == "" {
+		thread.Channel = callback.Channel.ID
+	}
+
+	var target alertTarget
+	if err := json.Unmarshal([]byte(action.Value), &target); err != nil {
+		klog.Warningf("Ignore: Slack action %s has invalid value %q: %v", action.ActionID, action.Value, err)
+		return
+	}
+	klog.Infof("Slack action: %s on %s/%s by %s\n", action.ActionID, target.Namespace, target.Pod, callback.User.Name)
+
+	var err error
+	switch action.ActionID {
+	case actionMuteWorkload:
+		until := time.Now().Add(workloadMuteDuration)
+		c.mutes.mute(target.workloadKey(), until)
+		_, err = c.slack.sendToThread(SlackMessage{
+			Title: fmt.Sprintf("<@%s> muted `%s` until %s", user, target.workloadKey(), until.Format(time.RFC1123Z)),
+		}, thread)
+	case actionAcknowledge:
+		_, err = c.slack.sendToThread(SlackMessage{
+			Title: fmt.Sprintf("<@%s> acknowledged the alert", user),
+			Color: "#2EB67D",
+		}, thread)
+	case actionFetchLogs:
+		err = c.uploadAlertLogs(target, thread)
+	case actionShowPodYAML:
+		err = c.uploadAlertPodYAML(target, thread)
+	default:
+		klog.Warningf("Ignore: unknown Slack action %s", action.ActionID)
+		return
+	}
+	if err != nil {
+		klog.Errorf("Failed while handling Slack action %s on %s/%s: %v", action.ActionID, target.Namespace, target.Pod, err)
+		c.slack.sendEphemeral(thread.Channel, user, fmt.Sprintf("Failed while handling `%s` on `%s/%s`: %v", action.ActionID, target.Namespace, target.Pod, err))
+	}
+}
+
+// getAlertPod returns the pod of the alert from the cache.
+func (c *Controller) getAlertPod(target alertTarget) (*v1.Pod, error) {
+	pod, err := c.getPodFromIndexer(target.Namespace + "/" + target.Pod)
+	if err != nil {
+		return nil, fmt.Errorf("pod %s/%s no longer exists", target.Namespace, target.Pod)
+	}
+	return pod, nil
+}
+
+// uploadAlertLogs uploads fetchLogLines more log lines than the alert of the restarted container to the alert thread.
+func (c *Controller) uploadAlertLogs(target alertTarget, thread SlackThread) error {
+	pod, err := c.getAlertPod(target)
+	if err != nil {
+		return err
+	}
+	status, ok := getContainerStatus(pod, target.Container)
+	if !ok {
+		return fmt.Errorf("container %s not found", target.Container)
+	}
+	previous := status.LastTerminationState.Terminated != nil
+	tailLines := c.getLogPolicy(pod).TailLines + fetchLogLines
+	logOptions := &v1.PodLogOptions{
+		Container:  target.Container,
+		Previous:   previous,
+		Timestamps: true,
+		TailLines:  &tailLines,
+	}
+	logs, err := c.clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, logOptions).DoRaw(context.TODO())
+	if err != nil {
+		return fmt.Errorf("got error while getting logs: %v", err)
+	}
+	content, _ := c.redactor.redact(string(logs))
+
+	filename := fmt.Sprintf("%s-%s.log", pod.Name, target.Container)
+	title := fmt.Sprintf("Last %d log lines of %s/%s container %s", tailLines, pod.Namespace, pod.Name, target.Container)
+	if previous {
+		filename = fmt.Sprintf("%s-%s.previous.log", pod.Name, target.Container)
+		title += " before restart"
+	}
+	return c.slack.uploadToThread(filename, title, content, thread)
+}
+
+// uploadAlertPodYAML uploads the pod manifest and status to the alert thread.
+func (c *Controller) uploadAlertPodYAML(target alertTarget, thread SlackThread) error {
+	pod, err := c.getAlertPod(target)
+	if err != nil {
+		return err
+	}
+	podObj := pod.DeepCopy()
+	podObj.APIVersion, podObj.Kind = "v1", "Pod"
+	podObj.ManagedFields = nil
+	c.redactor.redactEnv(podObj)
+	content, err := yaml.Marshal(podObj)
+	if err != nil {
+		return err
+	}
+	redacted, _ := c.redactor.redact(string(content))
+	return c.slack.uploadToThread(pod.Name+".yaml", fmt.Sprintf("Pod %s/%s", pod.Namespace, pod.Name), redacted, thread)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newSlackRequest(secret string, timestamp time.Time, body string) *http.Request {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":" + body))
+	r := httptest.NewRequest(http.MethodPost, "/slack/interactivity", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("X-Slack-Request-Timestamp", ts)
+	r.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func TestReadSlackRequest(t *testing.T) {
+	const secret = "8f742231b10e8888abcd99yyyzzz85a5"
+	body := "command=%2Frestartinfo&text=default%2Fweb-0"
+	tests := []struct {
+		name       string
+		request    *http.Request
+		wantStatus int
+	}{
+		{"valid request", newSlackRequest(secret, time.Now(), body), http.StatusOK},
+		{"bad signature", newSlackRequest("another-secret", time.Now(), body), http.StatusUnauthorized},
+		{"stale timestamp", newSlackRequest(secret, time.Now().Add(-10*time.Minute), body), http.StatusUnauthorized},
+		{"missing signature", httptest.NewRequest(http.MethodPost, "/slack/interactivity", strings.NewReader(body)), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Controller{slack: Slack{SigningSecret: secret}}
+			w := httptest.NewRecorder()
+			form, ok := c.readSlackRequest(w, tt.request)
+			if tt.wantStatus == http.StatusOK {
+				if !ok || form.Get("text") != "default/web-0" {
+					t.Errorf("readSlackRequest() = %v, %v, want the form of the verified request", form, ok)
+				}
+				return
+			}
+			if ok || w.Code != tt.wantStatus {
+				t.Errorf("readSlackRequest() = %v, status %d, want status %d", ok, w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
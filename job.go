@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/klog/v2"
+	"k8s.io/kubectl/pkg/describe"
+)
+
+// maxReportedJobPods limits how many failed pods of a Job are included in one message.
+const maxReportedJobPods = 3
+
+// jobKey is the work queue key of a failed Job, to tell it apart from Pod keys.
+type jobKey string
+
+func shouldReportFailedJobs() bool {
+	value, ok := os.LookupEnv("REPORT_FAILED_JOBS")
+	if !ok {
+		klog.Warningf("Environment variable REPORT_FAILED_JOBS is not set, default: %v\n", false)
+		return false
+	}
+	return value == "true"
+}
+
+// getJobFailedReason returns the reason why the Job failed, and whether it failed.
+func getJobFailedReason(job *batchv1.Job) (string, bool) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == v1.ConditionTrue {
+			if condition.Message != "" {
+				return fmt.Sprintf("%s: %s", condition.Reason, condition.Message), true
+			}
+			return condition.Reason, true
+		}
+	}
+	// The Failed condition may lag behind the failed pod count.
+	if job.Spec.BackoffLimit != nil && job.Status.Failed > *job.Spec.BackoffLimit {
+		return "BackoffLimitExceeded", true
+	}
+	return "", false
+}
+
+// getAndHandleJob gets the failed Job from the indexer and handles it.
+func (c *Controller) getAndHandleJob(key string) error {
+	obj, exists, err := c.jobInformer.Informer().GetIndexer().GetByKey(key)
+	if err != nil {
+		klog.Errorf("Fetching object with key %s from store failed with %v", key, err)
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%s not exists in indexer", key)
+	}
+
+	job, ok := obj.(*batchv1.Job)
+	if !ok {
+		klog.Error("Obj is not a valid Job object")
+		return fmt.Errorf("obj is not a valid Job object")
+	}
+	return c.handleJob(job)
+}
+
+// handleJob collects the failed pods of a Job and sends related info to slack.
+func (c *Controller) handleJob(job *batchv1.Job) error {
+	jobKey := job.Namespace + "/" + job.Name
+
+	currentTime := time.Now().Local()
+	failedPods, err := c.getFailedJobPods(job)
+	if err != nil {
+		return err
+	}
+	// The pod name prefixes select the Jobs by the names of their pods
+	if len(failedPods) > 0 && !hasWatchedPod(failedPods) {
+		klog.Infof("Ignore: job %s, its failed pods are not watched.\n", jobKey)
+		return nil
+	}
+
+	record := c.newJobIncidentRecord(job, nil)
+	if silence := c.silences.matchJob(job, failedPods, currentTime); silence != nil {
+		klog.Infof("Silenced: job %s by RestartSilence %s\n", jobKey, silence.Name)
+		c.silences.suppress(silence)
+		c.recordIncident(record, IncidentSilenced)
+		return nil
+	}
+	if window := c.checkMaintenance(record, currentTime); window != nil {
+		klog.Infof("Maintenance: job %s failed during maintenance window %s\n", jobKey, window)
+		c.recordIncident(record, IncidentMaintenance)
+		return nil
+	}
+	if lastSentTime, ok := c.slack.History[jobKey]; ok {
+		if int(currentTime.Sub(lastSentTime).Seconds()) < c.slack.MuteSeconds {
+			klog.Infof("Skip: %s, already sent %s ago.\n", jobKey, duration.HumanDuration(time.Since(lastSentTime)))
+			c.recordIncident(record, IncidentMuted)
+			return nil
+		}
+	}
+
+	failedReason, _ := getJobFailedReason(job)
+	klog.Infof("Handle: job %s failed, reason: %s\n", jobKey, failedReason)
+
+	incident, err := c.collectJobIncident(job, failedPods)
+	if err != nil {
+		return err
+	}
+
+	slackChannel := getSlackChannelFromJob(job)
+	msg := renderSlackMessage(incident)
+	// The message is sent with the default format if the templates fail
+	if templated, err := c.messageTemplates.render(incident, c.slack.getChannel(slackChannel), msg); err != nil {
+		klog.Errorf("Failed while rendering %s message templates: %v", jobKey, err)
+	} else {
+		msg = templated
+	}
+	err = c.slack.sendToChannel(msg, slackChannel)
+	if err != nil {
+		return err
+	}
+
+	// The incident store keeps the logs which do not fit in the message
+	record = c.newJobIncidentRecord(job, incident)
+	record.Title = msg.Title
+	record.Context = renderSlackText(incident, 0)
+	c.recordIncident(record, IncidentNotified)
+	c.slack.History[jobKey] = currentTime
+	c.cleanOldSlackHistory()
+	return nil
+}
+
+// collectJobIncident collects the context of the failed Job and of its most recent failed pods.
+func (c *Controller) collectJobIncident(job *batchv1.Job, failedPods []*v1.Pod) (*Incident, error) {
+	failedReason, _ := getJobFailedReason(job)
+	kind, name := getJobWorkload(job)
+	incident := &Incident{
+		Title:     "Job failed!",
+		Cluster:   c.slack.ClusterName,
+		Namespace: job.Namespace,
+		Owner:     IncidentOwner{Kind: kind, Name: name},
+		Labels:    job.Labels,
+		Reason:    failedReason,
+		Job:       &IncidentJob{Name: job.Name},
+	}
+
+	var err error
+	incident.Job.Status, err = printJob(job)
+	if err != nil {
+		return nil, err
+	}
+	// The CronJob is best effort, it may be deleted before the alert
+	incident.Job.CronJob, err = c.getCronJobInfo(job)
+	if err != nil {
+		klog.Warningf("Failed while getting %s/%s CronJob, omitted from the alert: %v", job.Namespace, job.Name, err)
+	}
+
+	for _, pod := range failedPods {
+		failedPod := IncidentJobPod{Name: pod.Name}
+		failedPod.Status, err = printPod(pod)
+		if err != nil {
+			return nil, err
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Terminated == nil || status.State.Terminated.ExitCode == 0 {
+				continue
+			}
+			containerState, err := describeContainerState(status)
+			if err != nil {
+				return nil, err
+			}
+			logs, err := c.getContainerLogs(pod, status, false)
+			if err != nil {
+				return nil, err
+			}
+			incident.Redactions += c.redactor.redactAll(&containerState, &logs)
+			failedPod.Status += containerState
+			containerLogs := ContainerLogs{Container: status.Name, Errors: c.logAnalyzer.extractErrors(logs)}
+			if logs != "" {
+				containerLogs.Logs = tailLines(logs, c.getLogPolicy(pod).TailLines)
+			}
+			failedPod.Containers = append(failedPod.Containers, containerLogs)
+		}
+		events, err := c.listPodEvents(pod)
+		if err != nil {
+			return nil, err
+		}
+		failedPod.Events = newIncidentEvents(events)
+		incident.Redactions += c.redactEvents(failedPod.Events)
+		incident.Job.FailedPods = append(incident.Job.FailedPods, failedPod)
+	}
+	return incident, nil
+}
+
+// getJobWorkload returns the CronJob owning the Job, or the Job itself.
+func getJobWorkload(job *batchv1.Job) (string, string) {
+	if owner := metav1.GetControllerOf(job); owner != nil && owner.Kind == "CronJob" {
+		return owner.Kind, owner.Name
+	}
+	return "Job", job.Name
+}
+
+// getSlackChannelFromJob gets custom slack channel from the Job, or from its pod template.
+func getSlackChannelFromJob(job *batchv1.Job) string {
+	if channel := getSlackChannelFromObject(job); channel != "" {
+		return channel
+	}
+	return getSlackChannelFromObject(&job.Spec.Template.ObjectMeta)
+}
+
+// getFailedJobPods returns the most recent failed pods of the Job.
+func (c *Controller) getFailedJobPods(job *batchv1.Job) ([]*v1.Pod, error) {
+	selector, err := metav1.LabelSelectorAsSelector(job.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("got error while parsing job selector: %v", err)
+	}
+	pods, err := c.podInformer.Lister().Pods(job.Namespace).List(selector)
+	if err != nil {
+		return nil, fmt.Errorf("got error while listing job pods: %v", err)
+	}
+
+	var failedPods []*v1.Pod
+	for _, pod := range pods {
+		if pod.Status.Phase == v1.PodFailed {
+			failedPods = append(failedPods, pod)
+		}
+	}
+	sort.Slice(failedPods, func(i, j int) bool {
+		return failedPods[j].CreationTimestamp.Before(&failedPods[i].CreationTimestamp)
+	})
+	if len(failedPods) > maxReportedJobPods {
+		failedPods = failedPods[:maxReportedJobPods]
+	}
+	return failedPods, nil
+}
+
+// hasWatchedPod returns whether one of the pods matches the watched and ignored pod name prefixes.
+func hasWatchedPod(pods []*v1.Pod) bool {
+	for _, pod := range pods {
+		if isWatchedPod(pod.Name) && !isIgnoredPod(pod.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// getCronJobInfo returns the schedule and last successful run of the CronJob owning the Job.
+func (c *Controller) getCronJobInfo(job *batchv1.Job) (string, error) {
+	owner := metav1.GetControllerOf(job)
+	if owner == nil || owner.Kind != "CronJob" {
+		return "", nil
+	}
+	cronJob, err := c.clientset.BatchV1().CronJobs(job.Namespace).Get(context.TODO(), owner.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("got error while getting the %s/%s CronJob: %v", job.Namespace, owner.Name, err)
+	}
+
+	lastSuccessfulTime := "<none>"
+	if cronJob.Status.LastSuccessfulTime != nil {
+		lastSuccessfulTime = fmt.Sprintf("%s (%s ago)", cronJob.Status.LastSuccessfulTime.Time.Format(time.RFC1123Z), translateTimestampSince(*cronJob.Status.LastSuccessfulTime))
+	}
+	return fmt.Sprintf("CronJob: `%s`, Schedule: `%s`, Last Successful Run: `%s`", cronJob.Name, cronJob.Spec.Schedule, lastSuccessfulTime), nil
+}
+
+func printJob(job *batchv1.Job) (string, error) {
+	var completions string
+	if job.Spec.Completions != nil {
+		completions = fmt.Sprintf("%d/%d", job.Status.Succeeded, *job.Spec.Completions)
+	} else {
+		completions = fmt.Sprintf("%d/1", job.Status.Succeeded)
+	}
+	backoffLimit := "<none>"
+	if job.Spec.BackoffLimit != nil {
+		backoffLimit = fmt.Sprintf("%d", *job.Spec.BackoffLimit)
+	}
+
+	return tabbedString(func(out io.Writer) error {
+		w := describe.NewPrefixWriter(out)
+		w.Write(describe.LEVEL_0, "NAME\tCOMPLETIONS\tFAILED\tBACKOFF LIMIT\tAGE\n")
+		w.Write(describe.LEVEL_0, "%s\t%s\t%d\t%s\t%s\n", job.Name, completions, job.Status.Failed, backoffLimit, translateTimestampSince(job.CreationTimestamp))
+		return nil
+	})
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newFailedJob returns a Job of the report CronJob which failed with one failed pod.
+func newFailedJob() (*batchv1.Job, *v1.Pod, *batchv1.CronJob) {
+	controller := true
+	backoffLimit := int32(0)
+	labels := map[string]string{"job-name": "report-28100"}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "batch",
+			Name:            "report-28100",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "CronJob", Name: "report", Controller: &controller}},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Selector:     &metav1.LabelSelector{MatchLabels: labels},
+			Template:     v1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: labels}},
+		},
+		Status: batchv1.JobStatus{
+			Failed:     1,
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: v1.ConditionTrue, Reason: "BackoffLimitExceeded", Message: "Job has reached the specified backoff limit"}},
+		},
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "batch", Name: "report-28100-x2k4p", Labels: labels},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "report"}}},
+		Status: v1.PodStatus{
+			Phase: v1.PodFailed,
+			ContainerStatuses: []v1.ContainerStatus{{
+				Name:  "report",
+				State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}},
+			}},
+		},
+	}
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "batch", Name: "report"},
+		Spec:       batchv1.CronJobSpec{Schedule: "0 * * * *"},
+	}
+	return job, pod, cronJob
+}
+
+// newJobTestController returns a Controller with the pods and namespaces in its informers.
+func newJobTestController(t *testing.T, pod *v1.Pod, objects ...runtime.Object) (*Controller, *testWebhook) {
+	clientset := fake.NewSimpleClientset(append(objects, pod)...)
+	filterEventsByUID(clientset)
+	informerFactory := informers.NewSharedInformerFactory(clientset, 0)
+	podInformer := informerFactory.Core().V1().Pods()
+	if err := podInformer.Informer().GetIndexer().Add(pod); err != nil {
+		t.Fatal(err)
+	}
+	namespaceInformer := informerFactory.Core().V1().Namespaces()
+	if err := namespaceInformer.Informer().GetIndexer().Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: pod.Namespace}}); err != nil {
+		t.Fatal(err)
+	}
+	slack, webhook := newTestSlack(t, http.StatusOK)
+	return &Controller{
+		clientset:         clientset,
+		slack:             slack,
+		silences:          &Silences{},
+		logPolicy:         LogPolicy{TailLines: 50},
+		maintenance:       &Maintenance{occurrences: make(map[string]*maintenanceOccurrence)},
+		eventsWindow:      time.Hour,
+		podInformer:       podInformer,
+		namespaceInformer: namespaceInformer,
+	}, webhook
+}
+
+func TestHandleJob(t *testing.T) {
+	job, pod, cronJob := newFailedJob()
+	c, webhook := newJobTestController(t, pod, job, cronJob)
+	c.slack.MuteSeconds = 600
+
+	if err := c.handleJob(job); err != nil {
+		t.Fatal(err)
+	}
+	// The failure is muted once sent
+	if err := c.handleJob(job); err != nil {
+		t.Fatal(err)
+	}
+	sent := webhook.sent()
+	if len(sent) != 1 {
+		t.Fatalf("handleJob() sent %d messages, want 1", len(sent))
+	}
+	if !strings.Contains(sent[0].Title, "Job failed!") {
+		t.Errorf("handleJob() title = %q", sent[0].Title)
+	}
+	for _, want := range []string{"CronJob: `report`, Schedule: `0 * * * *`", "report-28100-x2k4p", "fake logs", "BackoffLimitExceeded"} {
+		if !strings.Contains(sent[0].Text, want) {
+			t.Errorf("handleJob() text = %q, want %q", sent[0].Text, want)
+		}
+	}
+}
+
+func TestHandleJobNotWatched(t *testing.T) {
+	job, pod, cronJob := newFailedJob()
+	c, webhook := newJobTestController(t, pod, job, cronJob)
+	// The prefixes are matched against the failed pods, not the Job name
+	os.Setenv("WATCHED_POD_NAME_PREFIXES", "report-28100-")
+	defer os.Unsetenv("WATCHED_POD_NAME_PREFIXES")
+	if err := c.handleJob(job); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("WATCHED_POD_NAME_PREFIXES", "api")
+	if err := c.handleJob(job); err != nil {
+		t.Fatal(err)
+	}
+	if sent := webhook.sent(); len(sent) != 1 {
+		t.Errorf("handleJob() sent %d messages, want 1", len(sent))
+	}
+}
+
+func TestHandleJobSilenced(t *testing.T) {
+	job, pod, cronJob := newFailedJob()
+	c, webhook := newJobTestController(t, pod, job, cronJob)
+	silence := RestartSilence{
+		ObjectMeta: metav1.ObjectMeta{Name: "report-migration", UID: types.UID("uid-1"), CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour))},
+		Spec:       RestartSilenceSpec{Matchers: SilenceMatchers{Workload: "report", Reason: "Error"}, EndsAt: metav1.NewTime(time.Now().Add(time.Hour))},
+	}
+	matchers, err := silence.Spec.Matchers.compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.silences = &Silences{
+		Enabled:    true,
+		client:     dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+		silences:   map[types.UID]*compiledSilence{silence.UID: {silence: silence, matchers: matchers}},
+		suppressed: make(map[types.UID]int),
+		reported:   make(map[types.UID]bool),
+	}
+
+	if err := c.handleJob(job); err != nil {
+		t.Fatal(err)
+	}
+	if sent := webhook.sent(); len(sent) != 0 {
+		t.Errorf("handleJob() sent %d messages for a silenced Job", len(sent))
+	}
+	if c.silences.suppressed[silence.UID] != 1 {
+		t.Errorf("handleJob() suppressed count = %d, want 1", c.silences.suppressed[silence.UID])
+	}
+}
+
+func TestHandleJobMaintenance(t *testing.T) {
+	job, pod, cronJob := newFailedJob()
+	c, webhook := newJobTestController(t, pod, job, cronJob)
+	startsAt := metav1.NewTime(time.Now().Add(-time.Hour))
+	endsAt := metav1.NewTime(time.Now().Add(time.Hour))
+	window := MaintenanceWindow{Name: "reports", StartsAt: &startsAt, EndsAt: &endsAt, Workloads: []string{"report"}}
+	if err := window.compile(); err != nil {
+		t.Fatal(err)
+	}
+	c.maintenance.windows = []MaintenanceWindow{window}
+
+	if err := c.handleJob(job); err != nil {
+		t.Fatal(err)
+	}
+	if sent := webhook.sent(); len(sent) != 0 {
+		t.Errorf("handleJob() sent %d messages during a maintenance window", len(sent))
+	}
+}
+
+func TestShouldReportFailedJobs(t *testing.T) {
+	os.Unsetenv("REPORT_FAILED_JOBS")
+	if shouldReportFailedJobs() {
+		t.Errorf("shouldReportFailedJobs() = true by default")
+	}
+	os.Setenv("REPORT_FAILED_JOBS", "true")
+	defer os.Unsetenv("REPORT_FAILED_JOBS")
+	if !shouldReportFailedJobs() {
+		t.Errorf("shouldReportFailedJobs() = false with REPORT_FAILED_JOBS=true")
+	}
+}
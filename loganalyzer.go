@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// maxExceptionLines limits the lines of an extracted stack trace.
+	maxExceptionLines = 40
+	// maxErrorLines limits the number of extracted error lines.
+	maxErrorLines = 10
+	// maxExtractedLength limits the length of the extracted section, to leave room for the logs tail.
+	maxExtractedLength = 2500
+)
+
+var (
+	// logTimestampRegexp matches the timestamp prefix added by the Timestamps log option.
+	logTimestampRegexp = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\S+ `)
+	// goPanicRegexp matches the first line of a Go panic or fatal error.
+	goPanicRegexp = regexp.MustCompile(`^(panic: |fatal error: )`)
+	// pythonTracebackRegexp matches the first line of a Python traceback.
+	pythonTracebackRegexp = regexp.MustCompile(`^Traceback \(most recent call last\):`)
+	// exceptionRegexp matches the first line of a Java or Node.js exception.
+	exceptionRegexp = regexp.MustCompile(`^(Exception in thread .*|Uncaught .*|([\w$]+\.)*[\w$]*(Exception|Error)(: .*)?)$`)
+	// stackFrameRegexp matches the continuation lines of a Java or Node.js stack trace.
+	stackFrameRegexp = regexp.MustCompile(`^(\s+at |\s*Caused by: |\s+\.\.\. \d+ more)`)
+
+	defaultLogErrorPatterns = []string{
+		`\b(ERROR|FATAL|CRITICAL)\b`,
+		`(?i)\blevel=(error|fatal)\b`,
+		`(?i)"level":\s*"(error|fatal)"`,
+	}
+)
+
+// LogAnalyzer extracts stack traces and error lines from container logs.
+type LogAnalyzer struct {
+	TailLines     int64 // The number of log lines to fetch and analyze
+	errorPatterns []*regexp.Regexp
+}
+
+func getLogAnalyzerTailLines() int64 {
+	value := os.Getenv("LOG_ANALYZER_TAIL_LINES")
+	tailLines, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		tailLines = 500
+		klog.Warningf("Environment variable LOG_ANALYZER_TAIL_LINES is not set, default: %d\n", tailLines)
+	} else if tailLines <= 0 {
+		tailLines = 500
+		klog.Warningf("Environment variable LOG_ANALYZER_TAIL_LINES is invalid: %s, default: %d\n", value, tailLines)
+	}
+	return tailLines
+}
+
+// NewLogAnalyzer creates a LogAnalyzer with the error patterns, the default patterns are used if empty.
+func NewLogAnalyzer(errorPatterns []string) (LogAnalyzer, error) {
+	if len(errorPatterns) == 0 {
+		errorPatterns = defaultLogErrorPatterns
+	}
+	analyzer := LogAnalyzer{TailLines: getLogAnalyzerTailLines()}
+	for _, pattern := range errorPatterns {
+		errorRegexp, err := regexp.Compile(pattern)
+		if err != nil {
+			return analyzer, fmt.Errorf("invalid log error pattern %s: %v", pattern, err)
+		}
+		analyzer.errorPatterns = append(analyzer.errorPatterns, errorRegexp)
+	}
+	return analyzer, nil
+}
+
+// extractErrors returns the last stack trace (Go panic, Java, Python or Node.js) and the last error lines
+// of the logs, empty if there are none.
+func (analyzer LogAnalyzer) extractErrors(logs string) string {
+	lines := strings.Split(strings.TrimRight(logs, "\n"), "\n")
+	messages := make([]string, len(lines))
+	for i, line := range lines {
+		messages[i] = logTimestampRegexp.ReplaceAllString(line, "")
+	}
+
+	// The last stack trace is the closest to the restart
+	exceptionStart, exceptionEnd := 0, 0
+	for i := 0; i < len(messages); i++ {
+		end := findExceptionEnd(messages, i)
+		if end > i {
+			exceptionStart, exceptionEnd = i, end
+			i = end - 1
+		}
+	}
+	exception := lines[exceptionStart:exceptionEnd]
+	if len(exception) > maxExceptionLines {
+		exception = exception[:maxExceptionLines]
+	}
+
+	var errorLines []string
+	for i, message := range messages {
+		// Error lines of the stack trace are already included
+		if i >= exceptionStart && i < exceptionEnd {
+			continue
+		}
+		for _, errorRegexp := range analyzer.errorPatterns {
+			if errorRegexp.MatchString(message) {
+				errorLines = append(errorLines, lines[i])
+				break
+			}
+		}
+	}
+	if len(errorLines) > maxErrorLines {
+		errorLines = errorLines[len(errorLines)-maxErrorLines:]
+	}
+
+	out := strings.Join(append(append([]string{}, exception...), errorLines...), "\n")
+	if len(out) > maxExtractedLength {
+		// Cut at the start of a character, not in a multi-byte UTF-8 sequence
+		cut := maxExtractedLength
+		for cut > 0 && !utf8.RuneStart(out[cut]) {
+			cut--
+		}
+		out = out[:cut]
+	}
+	if out != "" {
+		out += "\n"
+	}
+	return out
+}
+
+// findExceptionEnd returns the end index of the stack trace starting at the start index,
+// or start if there is no stack trace starting there.
+func findExceptionEnd(messages []string, start int) int {
+	message := messages[start]
+	switch {
+	case goPanicRegexp.MatchString(message):
+		// A Go panic is followed by the goroutine traces until the process exits
+		end := start + maxExceptionLines
+		if end > len(messages) {
+			end = len(messages)
+		}
+		return end
+	case pythonTracebackRegexp.MatchString(message):
+		// A Python traceback ends with the first not indented line, the exception
+		end := start + 1
+		for end < len(messages) && strings.HasPrefix(messages[end], " ") {
+			end++
+		}
+		if end < len(messages) {
+			end++
+		}
+		return end
+	case exceptionRegexp.MatchString(message):
+		end := start + 1
+		for end < len(messages) && stackFrameRegexp.MatchString(messages[end]) {
+			end++
+		}
+		// An exception line without stack frames is an error line
+		if end == start+1 {
+			return start
+		}
+		return end
+	}
+	return start
+}
+
+// tailLines returns the last n lines of the logs, none if n <= 0.
+func tailLines(logs string, n int64) string {
+	if n <= 0 {
+		return ""
+	}
+	lines := strings.SplitAfter(logs, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if int64(len(lines)) <= n {
+		return logs
+	}
+	return strings.Join(lines[int64(len(lines))-n:], "")
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestExtractErrors(t *testing.T) {
+	analyzer, err := NewLogAnalyzer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		logs string
+		want string
+	}{
+		{
+			name: "no errors",
+			logs: "starting\nlistening on :8080\n",
+			want: "",
+		},
+		{
+			name: "error lines",
+			logs: "2024-01-01T00:00:00Z starting\n2024-01-01T00:00:01Z ERROR connection refused\n2024-01-01T00:00:02Z level=fatal msg=exiting\n",
+			want: "2024-01-01T00:00:01Z ERROR connection refused\n2024-01-01T00:00:02Z level=fatal msg=exiting\n",
+		},
+		{
+			name: "json level",
+			logs: `{"level": "error", "msg": "failed"}` + "\n" + `{"level": "info", "msg": "ok"}` + "\n",
+			want: `{"level": "error", "msg": "failed"}` + "\n",
+		},
+		{
+			name: "java exception before the error lines",
+			logs: "ERROR request failed\njava.lang.IllegalStateException: closed\n\tat com.example.Pool.get(Pool.java:42)\n\tat com.example.Main.main(Main.java:7)\nshutting down\n",
+			want: "java.lang.IllegalStateException: closed\n\tat com.example.Pool.get(Pool.java:42)\n\tat com.example.Main.main(Main.java:7)\nERROR request failed\n",
+		},
+		{
+			name: "python traceback with the exception line",
+			logs: "Traceback (most recent call last):\n  File \"app.py\", line 3, in <module>\n    main()\nValueError: invalid literal\nexiting\n",
+			want: "Traceback (most recent call last):\n  File \"app.py\", line 3, in <module>\n    main()\nValueError: invalid literal\n",
+		},
+		{
+			name: "last stack trace",
+			logs: "panic: first\n" + strings.Repeat("goroutine line\n", maxExceptionLines) + "java.lang.Error: second\n\tat Main.main(Main.java:1)\n",
+			want: "java.lang.Error: second\n\tat Main.main(Main.java:1)\n",
+		},
+		{
+			name: "exception without stack frames is not a stack trace",
+			logs: "java.lang.RuntimeException: boom\nok\n",
+			want: "",
+		},
+		{
+			name: "last error lines",
+			logs: strings.Repeat("ERROR old\n", maxErrorLines) + "ERROR new\n",
+			want: strings.Repeat("ERROR old\n", maxErrorLines-1) + "ERROR new\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := analyzer.extractErrors(tt.logs); got != tt.want {
+				t.Errorf("extractErrors() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractErrorsLength(t *testing.T) {
+	analyzer, err := NewLogAnalyzer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The multi-byte characters straddle the length limit
+	logs := "ERROR " + strings.Repeat("é", maxExtractedLength) + "\n"
+	got := analyzer.extractErrors(logs)
+	if len(got) > maxExtractedLength+1 {
+		t.Errorf("extractErrors() length = %d, want <= %d", len(got), maxExtractedLength+1)
+	}
+	if !utf8.ValidString(got) {
+		t.Errorf("extractErrors() cut a UTF-8 character")
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	tests := []struct {
+		name string
+		logs string
+		n    int64
+		want string
+	}{
+		{"fewer lines", "a\nb\n", 3, "a\nb\n"},
+		{"same lines", "a\nb\n", 2, "a\nb\n"},
+		{"more lines", "a\nb\nc\n", 2, "b\nc\n"},
+		{"no trailing newline", "a\nb\nc", 2, "b\nc"},
+		{"one line", "a\nb\nc\n", 1, "c\n"},
+		{"zero", "a\nb\n", 0, ""},
+		{"negative", "a\nb\n", -1, ""},
+		{"empty", "", 5, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tailLines(tt.logs, tt.n); got != tt.want {
+				t.Errorf("tailLines(%q, %d) = %q, want %q", tt.logs, tt.n, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// LogTailLinesKey is the pod annotation to override LOG_TAIL_LINES.
+	LogTailLinesKey = "alert-log-tail-lines"
+	// LogLimitBytesKey is the pod annotation to override LOG_LIMIT_BYTES.
+	LogLimitBytesKey = "alert-log-limit-bytes"
+	// LogSinceContainerStartKey is the pod annotation to override LOG_SINCE_CONTAINER_START, "true" or "false".
+	LogSinceContainerStartKey = "alert-log-since-container-start"
+)
+
+// LogPolicy defines how the logs of a terminated container are collected.
+type LogPolicy struct {
+	TailLines           int64 // The number of log lines shown before restart
+	LimitBytes          int64 // The maximum bytes of logs kept from the end of the logs, 0 for no limit
+	SinceContainerStart bool  // Only read the logs since the terminated container started
+}
+
+// NewLogPolicy creates the global log policy from the environment variables.
+func NewLogPolicy() LogPolicy {
+	value := os.Getenv("LOG_TAIL_LINES")
+	tailLines, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		tailLines = 50
+		klog.Warningf("Environment variable LOG_TAIL_LINES is not set, default: %d\n", tailLines)
+	} else if tailLines <= 0 {
+		tailLines = 50
+		klog.Warningf("Environment variable LOG_TAIL_LINES is invalid: %s, default: %d\n", value, tailLines)
+	}
+
+	value = os.Getenv("LOG_LIMIT_BYTES")
+	limitBytes, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		limitBytes = 0
+		klog.Warningf("Environment variable LOG_LIMIT_BYTES is not set, default: %d\n", limitBytes)
+	} else if limitBytes < 0 {
+		limitBytes = 0
+		klog.Warningf("Environment variable LOG_LIMIT_BYTES is invalid: %s, default: %d\n", value, limitBytes)
+	}
+
+	sinceContainerStart := os.Getenv("LOG_SINCE_CONTAINER_START") != "false"
+
+	klog.Infof("Log Policy: tailLines: %d, limitBytes: %d, sinceContainerStart: %v\n", tailLines, limitBytes, sinceContainerStart)
+	return LogPolicy{
+		TailLines:           tailLines,
+		LimitBytes:          limitBytes,
+		SinceContainerStart: sinceContainerStart,
+	}
+}
+
+// getLogPolicy returns the log policy of the pod, the global policy overridden by the pod annotations.
+func (c *Controller) getLogPolicy(pod *v1.Pod) LogPolicy {
+	policy := c.logPolicy
+	annotations := pod.GetAnnotations()
+	if value, ok := annotations[LogTailLinesKey]; ok {
+		if tailLines, err := strconv.ParseInt(value, 10, 64); err == nil && tailLines > 0 {
+			policy.TailLines = tailLines
+		} else {
+			klog.Warningf("Ignore: %s/%s has invalid %s annotation: %s\n", pod.Namespace, pod.Name, LogTailLinesKey, value)
+		}
+	}
+	if value, ok := annotations[LogLimitBytesKey]; ok {
+		if limitBytes, err := strconv.ParseInt(value, 10, 64); err == nil && limitBytes >= 0 {
+			policy.LimitBytes = limitBytes
+		} else {
+			klog.Warningf("Ignore: %s/%s has invalid %s annotation: %s\n", pod.Namespace, pod.Name, LogLimitBytesKey, value)
+		}
+	}
+	if value, ok := annotations[LogSinceContainerStartKey]; ok {
+		policy.SinceContainerStart = value == "true"
+	}
+	return policy
+}
+
+// podLogOptions returns the log options of the container terminated state, following the log policy.
+// The analyzer window is read when it is larger than the tail lines. The byte limit is not sent to the kubelet,
+// which applies it from the start of the stream, it is applied by readLastBytes to keep the end of the logs.
+func (policy LogPolicy) podLogOptions(containerName string, terminated *v1.ContainerStateTerminated, previous bool, analyzerTailLines int64) *v1.PodLogOptions {
+	tailLines := policy.TailLines
+	if analyzerTailLines > tailLines {
+		tailLines = analyzerTailLines
+	}
+	logOptions := &v1.PodLogOptions{
+		Container:  containerName,
+		Previous:   previous,
+		Timestamps: true,
+		TailLines:  &tailLines,
+	}
+	if policy.SinceContainerStart && terminated != nil && !terminated.StartedAt.IsZero() {
+		sinceTime := terminated.StartedAt
+		logOptions.SinceTime = &sinceTime
+	}
+	return logOptions
+}
+
+// readLastBytes reads the log stream and keeps its last limitBytes bytes, 0 for no limit. The kept logs start
+// at a line when the logs are cut. At most limitBytes and one read chunk are held in memory.
+func readLastBytes(r io.Reader, limitBytes int64) (string, error) {
+	if limitBytes <= 0 {
+		out, err := ioutil.ReadAll(r)
+		return string(out), err
+	}
+
+	// One more byte is kept to know whether the cut is at the start of a line
+	var chunks [][]byte
+	var size int64
+	for {
+		chunk := make([]byte, 32*1024)
+		n, err := io.ReadFull(r, chunk)
+		if n > 0 {
+			chunks = append(chunks, chunk[:n])
+			size += int64(n)
+			// Drop the oldest chunks which are not needed to keep limitBytes
+			for size-int64(len(chunks[0])) > limitBytes {
+				size -= int64(len(chunks[0]))
+				chunks = chunks[1:]
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	buf := bytes.Join(chunks, nil)
+	if int64(len(buf)) > limitBytes {
+		buf = buf[int64(len(buf))-limitBytes-1:]
+		// The end of the last line is kept if it is longer than the limit
+		if i := bytes.IndexByte(buf, '\n'); i >= 0 && i < len(buf)-1 {
+			buf = buf[i+1:]
+		} else {
+			buf = buf[1:]
+		}
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadLastBytes(t *testing.T) {
+	logs := "first\nsecond\nthird\n"
+	long := strings.Repeat("0123456789\n", 10000)
+	tests := []struct {
+		name       string
+		logs       string
+		limitBytes int64
+		want       string
+	}{
+		{"no limit", logs, 0, logs},
+		{"under the limit", logs, 100, logs},
+		{"at the limit", logs, int64(len(logs)), logs},
+		{"cut at a line start", logs, int64(len("third\n")), "third\n"},
+		{"cut in a line", logs, int64(len("d\nthird\n")), "third\n"},
+		{"cut in the last line", logs, 3, "rd\n"},
+		{"larger than a read chunk", long, 22, "0123456789\n0123456789\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readLastBytes(strings.NewReader(tt.logs), tt.limitBytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("readLastBytes(%d) = %q, want %q", tt.limitBytes, got, tt.want)
+			}
+		})
+	}
+}
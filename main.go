@@ -2,10 +2,14 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -13,23 +17,54 @@ import (
 	"k8s.io/klog/v2"
 )
 
+const usage = `Usage: k8s-pod-restart-info-collector [command] [flags]
+
+Commands:
+  run              Run the collector (default)
+  diagnose         Print the report of a pod, e.g. diagnose <namespace>/<pod>
+  test-notify      Send a synthetic incident to every configured destination
+  validate-config  Validate the configuration file
+  silence          Manage the RestartSilences
+
+Run "k8s-pod-restart-info-collector <command> -h" for the flags of a command.
+`
+
+// commands are the subcommands, they return the exit code.
+var commands = map[string]func(args []string) int{
+	"run":             runCollector,
+	"diagnose":        runDiagnoseCommand,
+	"test-notify":     runTestNotifyCommand,
+	"validate-config": runValidateConfigCommand,
+	"silence":         runSilenceCommand,
+}
+
 func main() {
-	var kubeconfig *string
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
-	} else {
-		kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
+	// The collector runs by default, also with the flags only for backward compatibility
+	name, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		fmt.Print(usage)
+		return
+	}
+	command, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", name, usage)
+		os.Exit(2)
 	}
-	flag.Parse()
+	os.Exit(command(args))
+}
+
+// runCollector runs the controller until the process is stopped.
+func runCollector(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	kubeconfig := addKubeconfigFlag(flags)
+	flags.Parse(args)
 
-	// use the current context in kubeconfig
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	config, err := buildRestConfig(*kubeconfig)
 	if err != nil {
-		// use InClusterConfig
-		config, err = rest.InClusterConfig()
-		if err != nil {
-			klog.Fatal(err)
-		}
+		klog.Fatal(err)
 	}
 
 	// creates the clientset
@@ -37,15 +72,51 @@ func main() {
 	if err != nil {
 		klog.Fatal(err)
 	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		klog.Fatal(err)
+	}
+
+	collectorConfig, err := LoadConfig(getConfigFile())
+	if err != nil {
+		klog.Exit(err)
+	}
 
 	slack := NewSlack()
-	controller := NewController(clientset, slack)
+	controller := NewController(clientset, dynamicClient, slack, collectorConfig)
+	if err := controller.startDigests(collectorConfig.Digests); err != nil {
+		klog.Exit(err)
+	}
 
 	// Start the controller
 	stop := make(chan struct{})
 	defer close(stop)
 	go controller.Run(1, stop)
 
+	if addr := getHTTPListenAddr(); addr != "" {
+		go func() {
+			klog.Fatal(controller.ListenAndServe(addr))
+		}()
+	}
+
 	// Wait forever
 	select {}
 }
+
+// addKubeconfigFlag adds the kubeconfig flag to the flag set.
+func addKubeconfigFlag(flags *flag.FlagSet) *string {
+	if home := homedir.HomeDir(); home != "" {
+		return flags.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
+	}
+	return flags.String("kubeconfig", "", "absolute path to the kubeconfig file")
+}
+
+// buildRestConfig uses the current context in kubeconfig, or the in-cluster config.
+func buildRestConfig(kubeconfig string) (*rest.Config, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		// use InClusterConfig
+		return rest.InClusterConfig()
+	}
+	return config, nil
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// MaintenanceWindowsKey is the namespace annotation of the maintenance windows of the namespace, a YAML or JSON list.
+	MaintenanceWindowsKey = "alert-maintenance-windows"
+	// maintenanceCheckInterval is the interval to check the closed maintenance windows.
+	maintenanceCheckInterval = time.Minute
+	// maintenanceSummaryTopN is the number of workloads listed in the summary.
+	maintenanceSummaryTopN = 10
+)
+
+var maintenanceScheduleParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// MaintenanceWindow is a recurring (schedule and duration) or one-off (startsAt and endsAt) time,
+// during which the restarts are recorded but not notified.
+type MaintenanceWindow struct {
+	Name     string          `json:"name,omitempty"`
+	Schedule string          `json:"schedule,omitempty"` // Cron expression of the window starts, e.g. "0 2 * * 6" for Saturdays at 02:00
+	Duration metav1.Duration `json:"duration,omitempty"` // The duration of the recurring window
+	Timezone string          `json:"timezone,omitempty"` // IANA time zone of the schedule, default: local time
+	StartsAt *metav1.Time    `json:"startsAt,omitempty"` // The start of the one-off window
+	EndsAt   *metav1.Time    `json:"endsAt,omitempty"`   // The end of the one-off window
+	// Namespaces are the regular expressions matching the whole namespace, default: all namespaces.
+	// They are ignored in the namespace annotation.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Workloads are the regular expressions matching the whole workload name, e.g. the Deployment, default: all workloads
+	Workloads []string `json:"workloads,omitempty"`
+	Summary   bool     `json:"summary,omitempty"` // Whether a summary of the restarts is sent once the window closes
+	Channel   string   `json:"channel,omitempty"` // The Slack channel of the summary, default: the channel of the restarts
+
+	schedule   cron.Schedule
+	namespaces []*regexp.Regexp
+	workloads  []*regexp.Regexp
+}
+
+// Maintenance evaluates the maintenance windows, and counts the restarts for the summaries.
+// The counts of the open windows are kept in memory, a restart of the collector loses them.
+type Maintenance struct {
+	windows []MaintenanceWindow
+	mu      sync.Mutex
+	// occurrences are the windows with restarts to summarize once closed, key: namespace/window/start/channel
+	occurrences map[string]*maintenanceOccurrence
+}
+
+// maintenanceOccurrence is an occurrence of a window with its restarts routed to a channel.
+type maintenanceOccurrence struct {
+	window    string
+	namespace string // Set for the windows of the namespace annotation
+	start     time.Time
+	end       time.Time
+	channel   string
+	workloads map[string]int
+	reasons   map[string]int
+	count     int
+}
+
+// NewMaintenance creates the Maintenance with the maintenance windows of the configuration file.
+func NewMaintenance(windows []MaintenanceWindow) (*Maintenance, error) {
+	for i := range windows {
+		if err := windows[i].compile(); err != nil {
+			return nil, fmt.Errorf("maintenance window %s is invalid: %v", windows[i], err)
+		}
+		klog.Infof("Maintenance window: %s\n", windows[i].describe())
+	}
+	return &Maintenance{
+		windows:     windows,
+		occurrences: make(map[string]*maintenanceOccurrence),
+	}, nil
+}
+
+// parseMaintenanceWindows parses the maintenance windows of the namespace annotation.
+func parseMaintenanceWindows(value string) ([]MaintenanceWindow, error) {
+	var windows []MaintenanceWindow
+	if err := yaml.UnmarshalStrict([]byte(value), &windows); err != nil {
+		return nil, err
+	}
+	for i := range windows {
+		windows[i].Namespaces = nil
+		if err := windows[i].compile(); err != nil {
+			return nil, fmt.Errorf("maintenance window %s is invalid: %v", windows[i], err)
+		}
+	}
+	return windows, nil
+}
+
+// compile validates the window, and parses its schedule, namespaces and workloads.
+func (window *MaintenanceWindow) compile() error {
+	switch {
+	case window.Schedule != "" && (window.StartsAt != nil || window.EndsAt != nil):
+		return fmt.Errorf("either schedule or startsAt and endsAt should be set")
+	case window.Schedule != "":
+		if window.Duration.Duration <= 0 {
+			return fmt.Errorf("duration is required with schedule")
+		}
+		spec := window.Schedule
+		if window.Timezone != "" {
+			spec = fmt.Sprintf("CRON_TZ=%s %s", window.Timezone, spec)
+		}
+		schedule, err := maintenanceScheduleParser.Parse(spec)
+		if err != nil {
+			return fmt.Errorf("invalid schedule %q: %v", spec, err)
+		}
+		window.schedule = schedule
+	case window.StartsAt != nil && window.EndsAt != nil:
+		if !window.EndsAt.After(window.StartsAt.Time) {
+			return fmt.Errorf("endsAt should be after startsAt")
+		}
+	default:
+		return fmt.Errorf("either schedule and duration or startsAt and endsAt are required")
+	}
+
+	var err error
+	if window.namespaces, err = compileWholeMatches(window.Namespaces); err != nil {
+		return fmt.Errorf("invalid namespace %v", err)
+	}
+	if window.workloads, err = compileWholeMatches(window.Workloads); err != nil {
+		return fmt.Errorf("invalid workload %v", err)
+	}
+	return nil
+}
+
+// compileWholeMatches compiles the regular expressions matching the whole value.
+func compileWholeMatches(patterns []string) ([]*regexp.Regexp, error) {
+	var out []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%q: %v", pattern, err)
+		}
+		out = append(out, re)
+	}
+	return out, nil
+}
+
+// occurrence returns the start and end of the window occurrence at now, false if the window is not open.
+func (window *MaintenanceWindow) occurrence(now time.Time) (time.Time, time.Time, bool) {
+	if window.schedule == nil {
+		start, end := window.StartsAt.Time, window.EndsAt.Time
+		return start, end, !now.Before(start) && now.Before(end)
+	}
+	// The last start before now is the first start after now - duration, if it is not after now.
+	start := window.schedule.Next(now.Add(-window.Duration.Duration))
+	if start.IsZero() || start.After(now) {
+		return time.Time{}, time.Time{}, false
+	}
+	return start, start.Add(window.Duration.Duration), true
+}
+
+// matches returns whether the window applies to the namespace and the workload of the restart.
+func (window *MaintenanceWindow) matches(incident *IncidentRecord) bool {
+	return matchesAny(window.namespaces, incident.Namespace) && matchesAny(window.workloads, incident.Workload)
+}
+
+// matchesAny returns whether one of the regular expressions matches the value, true if there are none.
+func matchesAny(res []*regexp.Regexp, value string) bool {
+	if len(res) == 0 {
+		return true
+	}
+	for _, re := range res {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the window name, or its schedule or start time if not set.
+func (window MaintenanceWindow) String() string {
+	switch {
+	case window.Name != "":
+		return window.Name
+	case window.Schedule != "":
+		return window.Schedule
+	case window.StartsAt != nil:
+		return window.StartsAt.Format(time.RFC3339)
+	}
+	return "<unnamed>"
+}
+
+// describe prints the window, e.g. "nightly: schedule: 0 2 * * *, duration: 2h, namespaces: [a b]".
+func (window MaintenanceWindow) describe() string {
+	var out string
+	if window.schedule != nil {
+		out = fmt.Sprintf("%s: schedule: %s, duration: %s", window, window.Schedule, window.Duration.Duration)
+		if window.Timezone != "" {
+			out += ", timezone: " + window.Timezone
+		}
+	} else {
+		out = fmt.Sprintf("%s: %s - %s", window, window.StartsAt.Format(time.RFC3339), window.EndsAt.Format(time.RFC3339))
+	}
+	if len(window.Namespaces) > 0 {
+		out += fmt.Sprintf(", namespaces: %v", window.Namespaces)
+	}
+	if len(window.Workloads) > 0 {
+		out += fmt.Sprintf(", workloads: %v", window.Workloads)
+	}
+	return out + fmt.Sprintf(", summary: %v", window.Summary)
+}
+
+// getNamespaceMaintenanceWindows returns the maintenance windows of the namespace annotation.
+func (c *Controller) getNamespaceMaintenanceWindows(name string) []MaintenanceWindow {
+	namespace, err := c.namespaceInformer.Lister().Get(name)
+	if err != nil {
+		klog.Warningf("Failed while getting namespace %s: %v", name, err)
+		return nil
+	}
+	value, ok := namespace.GetAnnotations()[MaintenanceWindowsKey]
+	if !ok {
+		return nil
+	}
+	windows, err := parseMaintenanceWindows(value)
+	if err != nil {
+		klog.Warningf("Ignore: namespace %s has invalid %s annotation: %v\n", name, MaintenanceWindowsKey, err)
+		return nil
+	}
+	return windows
+}
+
+// checkMaintenance returns the open maintenance window of the namespace annotation or the configuration file,
+// nil if there is none. The restart is counted for the summary of the window.
+func (c *Controller) checkMaintenance(incident *IncidentRecord, now time.Time) *MaintenanceWindow {
+	for _, source := range []struct {
+		namespace string
+		windows   []MaintenanceWindow
+	}{
+		{incident.Namespace, c.getNamespaceMaintenanceWindows(incident.Namespace)},
+		{"", c.maintenance.windows},
+	} {
+		for _, window := range source.windows {
+			if !window.matches(incident) {
+				continue
+			}
+			start, end, open := window.occurrence(now)
+			if !open {
+				continue
+			}
+			if window.Summary {
+				channel := window.Channel
+				if channel == "" {
+					channel = incident.Channel
+				}
+				c.maintenance.count(window, source.namespace, start, end, channel, incident)
+			}
+			window := window
+			return &window
+		}
+	}
+	return nil
+}
+
+// count counts the restart in the occurrence of the window.
+func (maintenance *Maintenance) count(window MaintenanceWindow, namespace string, start, end time.Time, channel string, incident *IncidentRecord) {
+	key := fmt.Sprintf("%s/%s/%d/%s", namespace, window, start.Unix(), channel)
+	maintenance.mu.Lock()
+	defer maintenance.mu.Unlock()
+	occurrence, ok := maintenance.occurrences[key]
+	if !ok {
+		occurrence = &maintenanceOccurrence{
+			window:    window.String(),
+			namespace: namespace,
+			start:     start,
+			end:       end,
+			channel:   channel,
+			workloads: map[string]int{},
+			reasons:   map[string]int{},
+		}
+		maintenance.occurrences[key] = occurrence
+	}
+	occurrence.workloads[incident.Namespace+"/"+incident.WorkloadKind+"/"+incident.Workload]++
+	occurrence.reasons[incident.Reason]++
+	occurrence.count++
+}
+
+// checkClosedMaintenance sends the summaries of the maintenance windows which closed.
+func (c *Controller) checkClosedMaintenance() {
+	now := time.Now()
+	var closed []*maintenanceOccurrence
+	c.maintenance.mu.Lock()
+	for key, occurrence := range c.maintenance.occurrences {
+		if !now.Before(occurrence.end) {
+			closed = append(closed, occurrence)
+			delete(c.maintenance.occurrences, key)
+		}
+	}
+	c.maintenance.mu.Unlock()
+
+	for _, occurrence := range closed {
+		if err := c.sendMaintenanceSummary(occurrence); err != nil {
+			klog.Errorf("Failed while sending maintenance window %s summary: %v", occurrence.window, err)
+		}
+	}
+}
+
+// sendMaintenanceSummary sends the restarts which were not notified during the window.
+func (c *Controller) sendMaintenanceSummary(occurrence *maintenanceOccurrence) error {
+	topWorkloads, err := printCounts("WORKLOAD", topCounts(occurrence.workloads, maintenanceSummaryTopN))
+	if err != nil {
+		return err
+	}
+	reasons, err := printCounts("REASON", topCounts(occurrence.reasons, len(occurrence.reasons)))
+	if err != nil {
+		return err
+	}
+
+	incident := &Incident{
+		Title:     "Maintenance window closed!",
+		Cluster:   c.slack.ClusterName,
+		Namespace: occurrence.namespace,
+		Maintenance: &IncidentMaintenanceWindow{
+			Window:       occurrence.window,
+			Start:        occurrence.start,
+			End:          occurrence.end,
+			Restarts:     occurrence.count,
+			TopWorkloads: topWorkloads,
+			Reasons:      reasons,
+		},
+	}
+	return c.slack.sendToChannel(renderSlackMessage(incident), occurrence.channel)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMaintenanceWindowOccurrence(t *testing.T) {
+	hongKong, err := time.LoadLocation("Asia/Hong_Kong")
+	if err != nil {
+		t.Skip(err)
+	}
+	saturday := time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC)
+	oneOffStart := metav1.NewTime(time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC))
+	oneOffEnd := metav1.NewTime(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))
+	weekly := MaintenanceWindow{Schedule: "0 2 * * 6", Duration: metav1.Duration{Duration: 4 * time.Hour}, Timezone: "UTC"}
+	// Over midnight, from 23:00 to 01:00 in Hong Kong (UTC+8)
+	nightly := MaintenanceWindow{Schedule: "0 23 * * *", Duration: metav1.Duration{Duration: 2 * time.Hour}, Timezone: "Asia/Hong_Kong"}
+	oneOff := MaintenanceWindow{StartsAt: &oneOffStart, EndsAt: &oneOffEnd}
+
+	tests := []struct {
+		name      string
+		window    MaintenanceWindow
+		now       time.Time
+		wantOpen  bool
+		wantStart time.Time
+	}{
+		{"weekly before", weekly, saturday.Add(time.Hour + 59*time.Minute), false, time.Time{}},
+		{"weekly at start", weekly, saturday.Add(2 * time.Hour), true, saturday.Add(2 * time.Hour)},
+		{"weekly during", weekly, saturday.Add(5 * time.Hour), true, saturday.Add(2 * time.Hour)},
+		{"weekly at end", weekly, saturday.Add(6 * time.Hour), false, time.Time{}},
+		{"weekly other day", weekly, saturday.Add(24*time.Hour + 3*time.Hour), false, time.Time{}},
+		{"nightly before midnight", nightly, time.Date(2024, 1, 1, 23, 30, 0, 0, hongKong), true, time.Date(2024, 1, 1, 23, 0, 0, 0, hongKong)},
+		{"nightly after midnight", nightly, time.Date(2024, 1, 2, 0, 30, 0, 0, hongKong), true, time.Date(2024, 1, 1, 23, 0, 0, 0, hongKong)},
+		{"nightly closed", nightly, time.Date(2024, 1, 2, 1, 0, 0, 0, hongKong), false, time.Time{}},
+		{"one-off before", oneOff, oneOffStart.Add(-time.Second), false, time.Time{}},
+		{"one-off during", oneOff, oneOffStart.Add(time.Hour), true, oneOffStart.Time},
+		{"one-off at end", oneOff, oneOffEnd.Time, false, oneOffStart.Time},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window := tt.window
+			if err := window.compile(); err != nil {
+				t.Fatal(err)
+			}
+			start, end, open := window.occurrence(tt.now)
+			if open != tt.wantOpen {
+				t.Fatalf("occurrence(%s) open = %v, want %v", tt.now, open, tt.wantOpen)
+			}
+			if open && (!start.Equal(tt.wantStart) || !end.After(tt.now)) {
+				t.Errorf("occurrence(%s) = %s - %s, want the start %s", tt.now, start, end, tt.wantStart)
+			}
+		})
+	}
+}
+
+func TestMaintenanceWindowCompile(t *testing.T) {
+	start := metav1.NewTime(time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC))
+	end := metav1.NewTime(start.Add(time.Hour))
+	hour := metav1.Duration{Duration: time.Hour}
+	tests := []struct {
+		name    string
+		window  MaintenanceWindow
+		wantErr bool
+	}{
+		{"recurring", MaintenanceWindow{Schedule: "0 2 * * 6", Duration: hour}, false},
+		{"one-off", MaintenanceWindow{StartsAt: &start, EndsAt: &end}, false},
+		{"schedule without duration", MaintenanceWindow{Schedule: "0 2 * * 6"}, true},
+		{"schedule and startsAt", MaintenanceWindow{Schedule: "0 2 * * 6", Duration: hour, StartsAt: &start}, true},
+		{"invalid schedule", MaintenanceWindow{Schedule: "0 2 * *", Duration: hour}, true},
+		{"invalid timezone", MaintenanceWindow{Schedule: "0 2 * * 6", Duration: hour, Timezone: "Mars/Base"}, true},
+		{"endsAt before startsAt", MaintenanceWindow{StartsAt: &end, EndsAt: &start}, true},
+		{"missing time", MaintenanceWindow{}, true},
+		{"invalid namespace", MaintenanceWindow{Schedule: "0 2 * * 6", Duration: hour, Namespaces: []string{"("}}, true},
+		{"invalid workload", MaintenanceWindow{Schedule: "0 2 * * 6", Duration: hour, Workloads: []string{"("}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window := tt.window
+			if err := window.compile(); (err != nil) != tt.wantErr {
+				t.Errorf("compile() = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMaintenanceWindowMatches(t *testing.T) {
+	window := MaintenanceWindow{
+		Schedule:   "0 2 * * 6",
+		Duration:   metav1.Duration{Duration: time.Hour},
+		Namespaces: []string{"payments-.*"},
+		Workloads:  []string{"api", "worker-.*"},
+	}
+	if err := window.compile(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		namespace string
+		workload  string
+		want      bool
+	}{
+		{"payments-prod", "api", true},
+		{"payments-prod", "worker-email", true},
+		{"payments-prod", "api-gateway", false},
+		{"payments", "api", false},
+		{"web", "api", false},
+	}
+	for _, tt := range tests {
+		if got := window.matches(&IncidentRecord{Namespace: tt.namespace, Workload: tt.workload}); got != tt.want {
+			t.Errorf("matches(%s, %s) = %v, want %v", tt.namespace, tt.workload, got, tt.want)
+		}
+	}
+}
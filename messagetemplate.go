@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/klog/v2"
+)
+
+// MessageTemplate overrides the title, text and footer of the pod restart alerts with Go templates, for all
+// the channels or for the alerts routed to a channel. The fields which are not set are not overridden.
+type MessageTemplate struct {
+	Channel string `json:"channel,omitempty"` // The Slack channel of the route, all the channels if empty
+	Title   string `json:"title,omitempty"`
+	Text    string `json:"text,omitempty"`
+	Footer  string `json:"footer,omitempty"`
+	title   *template.Template
+	text    *template.Template
+	footer  *template.Template
+}
+
+// MessageTemplates is the global template and the templates per channel.
+type MessageTemplates struct {
+	global   *MessageTemplate
+	channels map[string]*MessageTemplate
+}
+
+// messageTemplateData is the data of the templates, the incident fields and the default message.
+type messageTemplateData struct {
+	*Incident
+	Channel string
+	Default SlackMessage
+}
+
+var messageTemplateFuncs = template.FuncMap{
+	// truncate keeps the first n characters, e.g. {{ truncate 200 .Diagnosis }}
+	"truncate": func(n int, text string) string {
+		if n < 0 {
+			return text
+		}
+		return truncateText(text, n)
+	},
+	// tail keeps the last n lines, e.g. {{ tail 20 .Logs }}
+	"tail": func(n int, text string) string {
+		if text == "" || n < 0 {
+			return text
+		}
+		return tailLines(text, int64(n))
+	},
+	// codeBlock wraps the text in a Slack code block, empty if the text is empty
+	"codeBlock": func(text string) string {
+		if text == "" {
+			return ""
+		}
+		return fmt.Sprintf("```\n%s```\n", ensureTrailingNewline(text))
+	},
+	// humanizeDuration prints a duration, or the duration since a time, e.g. 5m or 3h10m
+	"humanizeDuration": func(value interface{}) (string, error) {
+		switch value := value.(type) {
+		case time.Duration:
+			return duration.HumanDuration(value), nil
+		case time.Time:
+			return duration.HumanDuration(time.Since(value)), nil
+		}
+		return "", fmt.Errorf("humanizeDuration expects a duration or a time, got %T", value)
+	},
+	// label looks up a label, empty if not set, e.g. {{ .Labels | label "team" }}
+	"label": func(key string, labels map[string]string) string {
+		return labels[key]
+	},
+}
+
+// NewMessageTemplates parses the templates, at most one template is global and one per channel.
+func NewMessageTemplates(templates []MessageTemplate) (*MessageTemplates, error) {
+	messageTemplates := &MessageTemplates{channels: make(map[string]*MessageTemplate)}
+	for i := range templates {
+		messageTemplate := &templates[i]
+		channel := strings.TrimPrefix(messageTemplate.Channel, "#")
+		if err := messageTemplate.parse(); err != nil {
+			return nil, fmt.Errorf("message template %d (channel %q) is invalid: %v", i, messageTemplate.Channel, err)
+		}
+		if channel == "" {
+			if messageTemplates.global != nil {
+				return nil, fmt.Errorf("message template %d: duplicated global template", i)
+			}
+			messageTemplates.global = messageTemplate
+			klog.Info("Message template: all channels\n")
+			continue
+		}
+		if _, ok := messageTemplates.channels[channel]; ok {
+			return nil, fmt.Errorf("message template %d: duplicated channel %s", i, messageTemplate.Channel)
+		}
+		messageTemplates.channels[channel] = messageTemplate
+		klog.Infof("Message template: channel: %s\n", messageTemplate.Channel)
+	}
+	return messageTemplates, nil
+}
+
+// parse parses the templates, the unknown fields fail when the templates are executed.
+func (messageTemplate *MessageTemplate) parse() error {
+	for _, field := range []struct {
+		name     string
+		text     string
+		template **template.Template
+	}{
+		{"title", messageTemplate.Title, &messageTemplate.title},
+		{"text", messageTemplate.Text, &messageTemplate.text},
+		{"footer", messageTemplate.Footer, &messageTemplate.footer},
+	} {
+		if field.text == "" {
+			continue
+		}
+		tmpl, err := template.New(field.name).Funcs(messageTemplateFuncs).Parse(field.text)
+		if err != nil {
+			return err
+		}
+		*field.template = tmpl
+	}
+	return nil
+}
+
+// render overrides the fields of the message with the templates of the channel, or the global templates.
+func (templates *MessageTemplates) render(incident *Incident, channel string, msg SlackMessage) (SlackMessage, error) {
+	if templates == nil {
+		return msg, nil
+	}
+	data := messageTemplateData{Incident: incident, Channel: channel, Default: msg}
+	var err error
+	if msg.Title, err = templates.execute(channel, data, msg.Title, func(t *MessageTemplate) *template.Template { return t.title }); err != nil {
+		return msg, err
+	}
+	if msg.Text, err = templates.execute(channel, data, msg.Text, func(t *MessageTemplate) *template.Template { return t.text }); err != nil {
+		return msg, err
+	}
+	// The default text fits in the Slack message, the templated text may not
+	msg.Text = truncateText(msg.Text, maxSlackTextLength)
+	if msg.Footer, err = templates.execute(channel, data, msg.Footer, func(t *MessageTemplate) *template.Template { return t.footer }); err != nil {
+		return msg, err
+	}
+	return msg, nil
+}
+
+// truncateText keeps the first n characters of the text, and marks the cut with "...".
+func truncateText(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	return string([]rune(text)[:n]) + "..."
+}
+
+// execute executes the field template of the channel, or the global one, and returns the default if none is set.
+func (templates *MessageTemplates) execute(channel string, data messageTemplateData, defaultText string,
+	field func(*MessageTemplate) *template.Template) (string, error) {
+	var tmpl *template.Template
+	if messageTemplate, ok := templates.channels[strings.TrimPrefix(channel, "#")]; ok {
+		tmpl = field(messageTemplate)
+	}
+	if tmpl == nil && templates.global != nil {
+		tmpl = field(templates.global)
+	}
+	if tmpl == nil {
+		return defaultText, nil
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return defaultText, err
+	}
+	return out.String(), nil
+}
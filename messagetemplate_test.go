@@ -0,0 +1,105 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessageTemplateFuncs(t *testing.T) {
+	labels := map[string]string{"team": "payments"}
+	tests := []struct {
+		name     string
+		template string
+		data     interface{}
+		want     string
+		wantErr  bool
+	}{
+		{"truncate", `{{ truncate 5 "hello world" }}`, nil, "hello...", false},
+		{"truncate short", `{{ truncate 20 "hello" }}`, nil, "hello", false},
+		{"truncate runes", `{{ truncate 2 "ééé" }}`, nil, "éé...", false},
+		{"truncate negative", `{{ truncate -1 "hello" }}`, nil, "hello", false},
+		{"tail", `{{ tail 2 "a\nb\nc\n" }}`, nil, "b\nc\n", false},
+		{"tail negative", `{{ tail -1 "a\nb\n" }}`, nil, "a\nb\n", false},
+		{"codeBlock", `{{ codeBlock "logs" }}`, nil, "```\nlogs\n```\n", false},
+		{"codeBlock empty", `{{ codeBlock "" }}`, nil, "", false},
+		{"humanizeDuration", `{{ humanizeDuration . }}`, 90 * time.Minute, "90m", false},
+		{"humanizeDuration time", `{{ humanizeDuration . }}`, time.Now().Add(-3 * time.Hour), "3h", false},
+		{"humanizeDuration invalid", `{{ humanizeDuration . }}`, "1h", "", true},
+		{"label", `{{ . | label "team" }}`, labels, "payments", false},
+		{"label missing", `{{ . | label "owner" }}`, labels, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messageTemplate := MessageTemplate{Text: tt.template}
+			if err := messageTemplate.parse(); err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			err := messageTemplate.text.Execute(&out, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() = %v, want error: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && out.String() != tt.want {
+				t.Errorf("Execute() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageTemplatesRender(t *testing.T) {
+	templates, err := NewMessageTemplates([]MessageTemplate{
+		{Title: "{{ .Title }} {{ .Pod }}", Footer: "global"},
+		{Channel: "#payments", Text: "{{ .Channel }}: {{ .Default.Text }}"},
+		{Channel: "long", Text: `{{ .Logs }}`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	incident := &Incident{Title: "Pod restarted!", Pod: "api-0", Logs: strings.Repeat("é", maxSlackTextLength+1)}
+	msg := SlackMessage{Title: "title", Text: "text", Footer: "footer"}
+	tests := []struct {
+		channel string
+		want    SlackMessage
+	}{
+		{"ops", SlackMessage{Title: "Pod restarted! api-0", Text: "text", Footer: "global"}},
+		{"payments", SlackMessage{Title: "Pod restarted! api-0", Text: "payments: text", Footer: "global"}},
+		{"long", SlackMessage{Title: "Pod restarted! api-0", Text: strings.Repeat("é", maxSlackTextLength) + "...", Footer: "global"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.channel, func(t *testing.T) {
+			got, err := templates.render(incident, tt.channel, msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("render() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	var none *MessageTemplates
+	if got, err := none.render(incident, "ops", msg); err != nil || !reflect.DeepEqual(got, msg) {
+		t.Errorf("render() without templates = %+v, %v, want the default message", got, err)
+	}
+}
+
+func TestNewMessageTemplatesErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates []MessageTemplate
+	}{
+		{"parse error", []MessageTemplate{{Text: "{{ .Pod "}}},
+		{"unknown function", []MessageTemplate{{Text: "{{ upper .Pod }}"}}},
+		{"duplicated global", []MessageTemplate{{Title: "a"}, {Text: "b"}}},
+		{"duplicated channel", []MessageTemplate{{Channel: "ops", Title: "a"}, {Channel: "#ops", Text: "b"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewMessageTemplates(tt.templates); err == nil {
+				t.Error("NewMessageTemplates() returned no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/kubectl/pkg/describe"
+)
+
+// NodeIncidents correlates pod restarts on the same node.
+type NodeIncidents struct {
+	PodThreshold int           // The number of restarted pods on a node to report a node incident, 0 to disable
+	Window       time.Duration // The time window to correlate restarts on a node
+	SlackChannel string        // Slack channel for node incidents, empty for the default channel
+	// Restarts stores recent restarts, key: nodeName, value: Namespace/podName -> restartTime
+	Restarts map[string]map[string]time.Time
+	// Active stores reported node incidents, key: nodeName, value: last correlated restartTime
+	Active map[string]time.Time
+}
+
+func NewNodeIncidents() NodeIncidents {
+	return NodeIncidents{
+		PodThreshold: getNodeIncidentPodThreshold(),
+		Window:       getNodeIncidentWindow(),
+		SlackChannel: os.Getenv("NODE_INCIDENT_SLACK_CHANNEL"),
+		Restarts:     make(map[string]map[string]time.Time),
+		Active:       make(map[string]time.Time),
+	}
+}
+
+// correlateNodeRestart records the pod restart on its node. It returns true when the restart is part of
+// a node incident, in which case the individual pod alert should be suppressed. The pod alert is sent if the
+// node incident cannot be sent.
+func (c *Controller) correlateNodeRestart(pod *v1.Pod) bool {
+	incidents := c.nodeIncidents
+	nodeName := pod.Spec.NodeName
+	if incidents.PodThreshold <= 0 || nodeName == "" {
+		return false
+	}
+
+	currentTime := time.Now().Local()
+	podKey := pod.Namespace + "/" + pod.Name
+	c.cleanOldNodeRestarts(currentTime)
+	if _, ok := incidents.Restarts[nodeName]; !ok {
+		incidents.Restarts[nodeName] = make(map[string]time.Time)
+	}
+	incidents.Restarts[nodeName][podKey] = currentTime
+
+	if _, ok := incidents.Active[nodeName]; ok {
+		incidents.Active[nodeName] = currentTime
+		klog.Infof("Skip: %s, part of the node %s incident.\n", podKey, nodeName)
+		return true
+	}
+
+	if len(incidents.Restarts[nodeName]) < incidents.PodThreshold {
+		return false
+	}
+
+	klog.Infof("Handle: node %s incident, %d pods restarted within %v\n", nodeName, len(incidents.Restarts[nodeName]), incidents.Window)
+	incident := c.collectNodeIncident(nodeName, incidents.Restarts[nodeName])
+	if err := c.slack.sendToChannel(renderSlackMessage(incident), incidents.SlackChannel); err != nil {
+		klog.Errorf("Failed while sending the node %s incident, the pod restart is sent instead: %v", nodeName, err)
+		return false
+	}
+	incidents.Active[nodeName] = currentTime
+	return true
+}
+
+// cleanOldNodeRestarts deletes restarts and node incidents older than the correlation window.
+func (c *Controller) cleanOldNodeRestarts(currentTime time.Time) {
+	incidents := c.nodeIncidents
+	for nodeName, restarts := range incidents.Restarts {
+		for podKey, restartTime := range restarts {
+			if currentTime.Sub(restartTime) > incidents.Window {
+				delete(restarts, podKey)
+			}
+		}
+		if len(restarts) == 0 {
+			delete(incidents.Restarts, nodeName)
+		}
+	}
+	for nodeName, lastRestartTime := range incidents.Active {
+		if currentTime.Sub(lastRestartTime) > incidents.Window {
+			klog.Infof("Node %s incident is over, no pod restarts within %v\n", nodeName, incidents.Window)
+			delete(incidents.Active, nodeName)
+		}
+	}
+}
+
+// collectNodeIncident collects the status, conditions and events of the node, and the restarted pods on it.
+// The node context is best effort, the node incident is sent without the context which cannot be collected.
+func (c *Controller) collectNodeIncident(nodeName string, restarts map[string]time.Time) *Incident {
+	incident := &Incident{
+		Title:        "Node incident!",
+		Cluster:      c.slack.ClusterName,
+		Node:         nodeName,
+		NodeIncident: &IncidentNode{RestartedPods: len(restarts)},
+	}
+
+	node, err := c.clientset.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+	if err != nil {
+		klog.Warningf("Failed while getting the %s Node, omitted from the node incident: %v", nodeName, err)
+	} else {
+		if incident.NodeStatus, err = printNode(node, c.getNodePods(nodeName)); err != nil {
+			klog.Warningf("Failed while printing the %s Node: %v", nodeName, err)
+		}
+		if incident.NodeIncident.Conditions, err = describeNodeConditions(node); err != nil {
+			klog.Warningf("Failed while describing the %s Node conditions: %v", nodeName, err)
+		}
+	}
+	if events, err := c.listNodeEvents(nodeName); err != nil {
+		klog.Warningf("Failed while getting the %s Node events, omitted from the node incident: %v", nodeName, err)
+	} else {
+		incident.NodeEvents = newIncidentEvents(events)
+	}
+	if incident.NodeIncident.AffectedPods, err = printAffectedPods(restarts); err != nil {
+		klog.Warningf("Failed while printing the %s Node affected pods: %v", nodeName, err)
+	}
+	incident.Redactions = c.redactor.redactAll(&incident.NodeIncident.Conditions) + c.redactEvents(incident.NodeEvents)
+	return incident
+}
+
+func printAffectedPods(restarts map[string]time.Time) (string, error) {
+	podKeys := make([]string, 0, len(restarts))
+	for podKey := range restarts {
+		podKeys = append(podKeys, podKey)
+	}
+	sort.Strings(podKeys)
+
+	return tabbedString(func(out io.Writer) error {
+		w := describe.NewPrefixWriter(out)
+		w.Write(describe.LEVEL_0, "POD\tLAST RESTART\n")
+		for _, podKey := range podKeys {
+			w.Write(describe.LEVEL_0, "%s\t%s ago\n", podKey, translateTimestampSince(metav1.NewTime(restarts[podKey])))
+		}
+		return nil
+	})
+}
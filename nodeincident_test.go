@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newNodeIncidentPod(name string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+	}
+}
+
+func TestCorrelateNodeRestart(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	// The node context is best effort, the node incident is sent without it
+	clientset.PrependReactor("get", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("forbidden")
+	})
+	slack, webhook := newTestSlack(t, http.StatusOK)
+	nodeIncidents := NewNodeIncidents()
+	nodeIncidents.PodThreshold = 2
+	c := &Controller{clientset: clientset, slack: slack, nodeIncidents: nodeIncidents, eventsWindow: time.Hour}
+
+	if c.correlateNodeRestart(newNodeIncidentPod("web-0")) {
+		t.Fatalf("correlateNodeRestart() suppressed the restart below the threshold")
+	}
+	if !c.correlateNodeRestart(newNodeIncidentPod("web-1")) {
+		t.Fatalf("correlateNodeRestart() did not report the node incident at the threshold")
+	}
+	if !c.correlateNodeRestart(newNodeIncidentPod("web-2")) {
+		t.Fatalf("correlateNodeRestart() did not suppress the restart of the active node incident")
+	}
+
+	sent := webhook.sent()
+	if len(sent) != 1 {
+		t.Fatalf("correlateNodeRestart() sent %d messages, want 1", len(sent))
+	}
+	if want := "*Node incident!*\n*cluster: `test`, node: `node-1`, restarted pods: `2`*"; sent[0].Title != want {
+		t.Errorf("node incident title = %q, want %q", sent[0].Title, want)
+	}
+	for _, want := range []string{"• Node Status Unavailable\n", "• No Node Events\n", "default/web-0", "default/web-1"} {
+		if !strings.Contains(sent[0].Text, want) {
+			t.Errorf("node incident text = %q, want %q", sent[0].Text, want)
+		}
+	}
+}
+
+func TestCorrelateNodeRestartSendFailure(t *testing.T) {
+	slack, _ := newTestSlack(t, http.StatusInternalServerError)
+	nodeIncidents := NewNodeIncidents()
+	nodeIncidents.PodThreshold = 1
+	c := &Controller{clientset: fake.NewSimpleClientset(), slack: slack, nodeIncidents: nodeIncidents, eventsWindow: time.Hour}
+
+	// The pod alert is sent instead of the node incident
+	if c.correlateNodeRestart(newNodeIncidentPod("web-0")) {
+		t.Errorf("correlateNodeRestart() suppressed the restart although the node incident was not sent")
+	}
+	if _, ok := c.nodeIncidents.Active["node-1"]; ok {
+		t.Errorf("correlateNodeRestart() recorded the node incident which was not sent")
+	}
+}
+
+func TestCorrelateNodeRestartDisabledByDefault(t *testing.T) {
+	slack, webhook := newTestSlack(t, http.StatusOK)
+	c := &Controller{clientset: fake.NewSimpleClientset(), slack: slack, nodeIncidents: NewNodeIncidents(), eventsWindow: time.Hour}
+
+	for _, name := range []string{"web-0", "web-1", "web-2", "web-3"} {
+		if c.correlateNodeRestart(newNodeIncidentPod(name)) {
+			t.Fatalf("correlateNodeRestart() suppressed the restart, node incidents are disabled by default")
+		}
+	}
+	if sent := webhook.sent(); len(sent) != 0 {
+		t.Errorf("correlateNodeRestart() sent %d messages, want 0", len(sent))
+	}
+}
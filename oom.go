@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/kubectl/pkg/describe"
+)
+
+// systemOOMWindow is the time window around the container termination to look for a SystemOOM node event.
+const systemOOMWindow = 2 * time.Minute
+
+// podMetrics is the subset of the metrics.k8s.io/v1beta1 PodMetrics used by the collector.
+type podMetrics struct {
+	Timestamp  metav1.Time     `json:"timestamp"`
+	Window     metav1.Duration `json:"window"`
+	Containers []struct {
+		Name  string          `json:"name"`
+		Usage v1.ResourceList `json:"usage"`
+	} `json:"containers"`
+}
+
+// prometheusResponse is the subset of the Prometheus instant query response used by the collector.
+type prometheusResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		Result []struct {
+			Value []interface{} `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// getPrometheusURL returns the optional Prometheus-compatible endpoint to query the memory usage history.
+func getPrometheusURL() string {
+	return os.Getenv("PROMETHEUS_URL")
+}
+
+// getOOMContext returns the memory usage of an OOMKilled container compared with its limits and requests,
+// the node memory pressure, and whether the kill was container-level or node-level.
+func (c *Controller) getOOMContext(pod *v1.Pod, status v1.ContainerStatus, container v1.Container) (string, error) {
+	terminated := status.LastTerminationState.Terminated
+	if terminated == nil || terminated.Reason != "OOMKilled" {
+		return "", nil
+	}
+
+	limit, hasLimit := container.Resources.Limits[v1.ResourceMemory]
+	request, hasRequest := container.Resources.Requests[v1.ResourceMemory]
+
+	currentUsage := "<unavailable>"
+	if usage, err := c.getContainerMemoryUsage(pod, status.Name); err != nil {
+		klog.Warningf("Failed while getting %s/%s metrics: %v", pod.Namespace, pod.Name, err)
+	} else if usage != nil {
+		currentUsage = printMemoryUsage(*usage, limit, hasLimit)
+	}
+
+	peakUsage := ""
+	if prometheusURL := getPrometheusURL(); prometheusURL != "" {
+		peakUsage = "<unavailable>"
+		if usage, err := queryPeakMemoryUsage(prometheusURL, pod, status.Name, terminated); err != nil {
+			klog.Warningf("Failed while querying %s/%s memory usage from Prometheus: %v", pod.Namespace, pod.Name, err)
+		} else if usage != nil {
+			peakUsage = printMemoryUsage(*usage, limit, hasLimit)
+		}
+	}
+
+	memoryPressure := "<unknown>"
+	node, err := c.clientset.CoreV1().Nodes().Get(context.TODO(), pod.Spec.NodeName, metav1.GetOptions{})
+	if err != nil {
+		klog.Warningf("Failed while getting the %s Node: %v", pod.Spec.NodeName, err)
+	} else {
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == v1.NodeMemoryPressure {
+				memoryPressure = string(condition.Status)
+				if condition.Status == v1.ConditionTrue {
+					memoryPressure = fmt.Sprintf("%s, %s", condition.Status, condition.Message)
+				}
+			}
+		}
+	}
+
+	oomKill := c.getOOMKillLevel(pod.Spec.NodeName, terminated, limit, hasLimit)
+
+	return tabbedString(func(out io.Writer) error {
+		w := describe.NewPrefixWriter(out)
+		if hasLimit {
+			w.Write(describe.LEVEL_0, "Limit:\t%s\n", limit.String())
+		} else {
+			w.Write(describe.LEVEL_0, "Limit:\t<none>\n")
+		}
+		if hasRequest {
+			w.Write(describe.LEVEL_0, "Request:\t%s\n", request.String())
+		}
+		// metrics.k8s.io only has the usage of the running container, not the usage at the kill
+		w.Write(describe.LEVEL_0, "Current Usage (new container):\t%s\n", currentUsage)
+		if peakUsage != "" {
+			w.Write(describe.LEVEL_0, "Peak Usage Before Kill:\t%s\n", peakUsage)
+		}
+		w.Write(describe.LEVEL_0, "Node MemoryPressure:\t%s\n", memoryPressure)
+		w.Write(describe.LEVEL_0, "OOM Kill:\t%s\n", oomKill)
+		return nil
+	})
+}
+
+// getContainerMemoryUsage gets the current memory working set of the container from metrics.k8s.io,
+// nil if the container has no metrics yet.
+func (c *Controller) getContainerMemoryUsage(pod *v1.Pod, containerName string) (*resource.Quantity, error) {
+	data, err := c.clientset.CoreV1().RESTClient().Get().
+		AbsPath("/apis/metrics.k8s.io/v1beta1/namespaces", pod.Namespace, "pods", pod.Name).
+		DoRaw(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	var metrics podMetrics
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return nil, err
+	}
+	for _, container := range metrics.Containers {
+		if container.Name == containerName {
+			if usage, ok := container.Usage[v1.ResourceMemory]; ok {
+				return &usage, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// queryPeakMemoryUsage queries the peak memory working set of the terminated container from Prometheus,
+// nil if there is no data.
+func queryPeakMemoryUsage(prometheusURL string, pod *v1.Pod, containerName string, terminated *v1.ContainerStateTerminated) (*resource.Quantity, error) {
+	lifetime := terminated.FinishedAt.Sub(terminated.StartedAt.Time)
+	if lifetime < time.Minute {
+		lifetime = time.Minute
+	}
+	if lifetime > time.Hour {
+		lifetime = time.Hour
+	}
+
+	query := fmt.Sprintf(`max(max_over_time(container_memory_working_set_bytes{namespace=%q,pod=%q,container=%q}[%ds]))`,
+		pod.Namespace, pod.Name, containerName, int(lifetime.Seconds()))
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("time", strconv.FormatInt(terminated.FinishedAt.Unix(), 10))
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(prometheusURL + "/api/v1/query?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("got HTTP %d from Prometheus: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result prometheusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("got error while decoding Prometheus response: %v", err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("got error from Prometheus: %s", result.Error)
+	}
+	if len(result.Data.Result) == 0 || len(result.Data.Result[0].Value) != 2 {
+		return nil, nil
+	}
+	value, ok := result.Data.Result[0].Value[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("got unexpected Prometheus value %v", result.Data.Result[0].Value[1])
+	}
+	bytes, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	return resource.NewQuantity(int64(bytes), resource.BinarySI), nil
+}
+
+// getOOMKillLevel tells whether the container was killed for exceeding its own memory limit,
+// or by a system OOM of the node, unavailable if the node events cannot be listed.
+func (c *Controller) getOOMKillLevel(nodeName string, terminated *v1.ContainerStateTerminated, limit resource.Quantity, hasLimit bool) string {
+	events, err := c.listNodeEvents(nodeName)
+	if err != nil {
+		klog.Warningf("Failed while getting the %s Node events: %v", nodeName, err)
+		return "<unavailable>"
+	}
+	for _, event := range events {
+		if event.Reason != "SystemOOM" {
+			continue
+		}
+		if event.LastTimestamp.Time.After(terminated.FinishedAt.Add(-systemOOMWindow)) && event.LastTimestamp.Time.Before(terminated.FinishedAt.Add(systemOOMWindow)) {
+			return fmt.Sprintf("node-level, %s", event.Message)
+		}
+	}
+	if hasLimit {
+		return fmt.Sprintf("container-level, exceeded the memory limit %s", limit.String())
+	}
+	return "unknown, no memory limit and no SystemOOM event on the node"
+}
+
+// printMemoryUsage prints the memory usage in MiB with the percentage of the limit.
+func printMemoryUsage(usage resource.Quantity, limit resource.Quantity, hasLimit bool) string {
+	out := fmt.Sprintf("%dMi", usage.Value()/(1024*1024))
+	if hasLimit {
+		out += fmt.Sprintf(" (%d%% of limit)", getQuantityPercentage(usage, limit))
+	}
+	return out
+}
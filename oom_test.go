@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestGetOOMKillLevel(t *testing.T) {
+	finishedAt := time.Now().Add(-5 * time.Minute)
+	terminated := &v1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137, FinishedAt: metav1.NewTime(finishedAt)}
+	systemOOM := func(lastTimestamp time.Time) *v1.Event {
+		return &v1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Namespace: "default", Name: "oom"},
+			InvolvedObject: v1.ObjectReference{Kind: "Node", Name: "node-1"},
+			Reason:         "SystemOOM",
+			Message:        "System OOM encountered, victim process: java",
+			LastTimestamp:  metav1.NewTime(lastTimestamp),
+		}
+	}
+	tests := []struct {
+		name     string
+		events   []runtime.Object
+		hasLimit bool
+		listErr  error
+		want     string
+	}{
+		{"node-level", []runtime.Object{systemOOM(finishedAt.Add(30 * time.Second))}, true, nil, "node-level, System OOM encountered, victim process: java"},
+		{"container-level", nil, true, nil, "container-level, exceeded the memory limit 512Mi"},
+		{"SystemOOM outside the window", []runtime.Object{systemOOM(finishedAt.Add(-10 * time.Minute))}, true, nil, "container-level, exceeded the memory limit 512Mi"},
+		{"no limit", nil, false, nil, "unknown, no memory limit and no SystemOOM event on the node"},
+		{"events unavailable", nil, true, errors.New("forbidden"), "<unavailable>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tt.events...)
+			if tt.listErr != nil {
+				clientset.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, tt.listErr
+				})
+			}
+			c := &Controller{clientset: clientset, eventsWindow: time.Hour}
+			got := c.getOOMKillLevel("node-1", terminated, resource.MustParse("512Mi"), tt.hasLimit)
+			if got != tt.want {
+				t.Errorf("getOOMKillLevel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryPeakMemoryUsage(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-0"}}
+	terminated := &v1.ContainerStateTerminated{
+		StartedAt:  metav1.NewTime(time.Now().Add(-10 * time.Minute)),
+		FinishedAt: metav1.NewTime(time.Now()),
+	}
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    string // The quantity, empty for no data
+		wantErr string
+	}{
+		{"peak usage", http.StatusOK, `{"status":"success","data":{"result":[{"value":[1700000000,"536870912"]}]}}`, "512Mi", ""},
+		{"no data", http.StatusOK, `{"status":"success","data":{"result":[]}}`, "", ""},
+		{"query error", http.StatusOK, `{"status":"error","error":"parse error"}`, "", "got error from Prometheus: parse error"},
+		{"HTTP error", http.StatusServiceUnavailable, "overloaded\n", "", "got HTTP 503 from Prometheus: overloaded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var query string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query = r.URL.Query().Get("query")
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			usage, err := queryPeakMemoryUsage(server.URL, pod, "app", terminated)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("queryPeakMemoryUsage() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			if usage != nil {
+				got = usage.String()
+			}
+			if got != tt.want {
+				t.Errorf("queryPeakMemoryUsage() = %q, want %q", got, tt.want)
+			}
+			// The peak over the life of the terminated container
+			if !strings.Contains(query, `pod="web-0",container="app"}[600s]`) {
+				t.Errorf("queryPeakMemoryUsage() query = %q", query)
+			}
+		})
+	}
+}
+
+func TestPrintMemoryUsage(t *testing.T) {
+	usage := resource.MustParse("384Mi")
+	if got, want := printMemoryUsage(usage, resource.MustParse("512Mi"), true), "384Mi (75% of limit)"; got != want {
+		t.Errorf("printMemoryUsage() = %q, want %q", got, want)
+	}
+	if got, want := printMemoryUsage(usage, resource.Quantity{}, false), "384Mi"; got != want {
+		t.Errorf("printMemoryUsage() without limit = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// getOwnerWorkload gets the workload controlling the pod: the Deployment of a ReplicaSet, a StatefulSet,
+// a DaemonSet, the CronJob of a Job, or the ReplicaSet and Job themselves. Nil is returned for bare pods.
+func (c *Controller) getOwnerWorkload(pod *v1.Pod) (interface{}, error) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return nil, nil
+	}
+
+	ctx := context.TODO()
+	switch owner.Kind {
+	case "ReplicaSet":
+		replicaSet, err := c.clientset.AppsV1().ReplicaSets(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if rsOwner := metav1.GetControllerOf(replicaSet); rsOwner != nil && rsOwner.Kind == "Deployment" {
+			deployment, err := c.clientset.AppsV1().Deployments(pod.Namespace).Get(ctx, rsOwner.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			deployment.APIVersion, deployment.Kind = "apps/v1", "Deployment"
+			deployment.ManagedFields = nil
+			return deployment, nil
+		}
+		replicaSet.APIVersion, replicaSet.Kind = "apps/v1", "ReplicaSet"
+		replicaSet.ManagedFields = nil
+		return replicaSet, nil
+	case "StatefulSet":
+		statefulSet, err := c.clientset.AppsV1().StatefulSets(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		statefulSet.APIVersion, statefulSet.Kind = "apps/v1", "StatefulSet"
+		statefulSet.ManagedFields = nil
+		return statefulSet, nil
+	case "DaemonSet":
+		daemonSet, err := c.clientset.AppsV1().DaemonSets(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		daemonSet.APIVersion, daemonSet.Kind = "apps/v1", "DaemonSet"
+		daemonSet.ManagedFields = nil
+		return daemonSet, nil
+	case "Job":
+		job, err := c.clientset.BatchV1().Jobs(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if jobOwner := metav1.GetControllerOf(job); jobOwner != nil && jobOwner.Kind == "CronJob" {
+			cronJob, err := c.clientset.BatchV1().CronJobs(pod.Namespace).Get(ctx, jobOwner.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			cronJob.APIVersion, cronJob.Kind = "batch/v1", "CronJob"
+			cronJob.ManagedFields = nil
+			return cronJob, nil
+		}
+		job.APIVersion, job.Kind = "batch/v1", "Job"
+		job.ManagedFields = nil
+		return job, nil
+	}
+	return nil, nil
+}
+
+// getPodWorkload returns the kind and name of the workload of the pod from its owner references, without API calls.
+// The Deployment name is derived from the ReplicaSet name and the pod-template-hash label.
+func getPodWorkload(pod *v1.Pod) (string, string) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return "Pod", pod.Name
+	}
+	if owner.Kind == "ReplicaSet" {
+		if hash, ok := pod.Labels["pod-template-hash"]; ok && strings.HasSuffix(owner.Name, "-"+hash) {
+			return "Deployment", strings.TrimSuffix(owner.Name, "-"+hash)
+		}
+	}
+	return owner.Kind, owner.Name
+}
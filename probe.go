@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubectl/pkg/describe"
+)
+
+const (
+	livenessProbe  = "Liveness"
+	readinessProbe = "Readiness"
+	startupProbe   = "Startup"
+)
+
+// probeFailure is a probe failure event of a container.
+type probeFailure struct {
+	probeType string
+	event     v1.Event
+}
+
+// getProbeFailures returns the probe failure events of the container during the life of its last terminated
+// instance, and the probe type which killed the container, empty if it was not killed by a probe.
+func getProbeFailures(status v1.ContainerStatus, events []v1.Event) ([]probeFailure, string) {
+	terminated := status.LastTerminationState.Terminated
+	if terminated == nil {
+		return nil, ""
+	}
+
+	fieldPath := fmt.Sprintf("spec.containers{%s}", status.Name)
+	var failures []probeFailure
+	var killedBy string
+	for _, event := range events {
+		if event.InvolvedObject.FieldPath != fieldPath {
+			continue
+		}
+		// Aggregated events may span several container lifetimes
+		firstTimestamp := event.FirstTimestamp
+		if firstTimestamp.IsZero() {
+			firstTimestamp = event.LastTimestamp
+		}
+		if event.LastTimestamp.Before(&terminated.StartedAt) || terminated.FinishedAt.Before(&firstTimestamp) {
+			continue
+		}
+
+		switch event.Reason {
+		case "Unhealthy":
+			for _, probeType := range []string{livenessProbe, readinessProbe, startupProbe} {
+				if strings.HasPrefix(event.Message, probeType+" probe") {
+					failures = append(failures, probeFailure{probeType: probeType, event: event})
+					break
+				}
+			}
+		case "Killing":
+			// e.g. Container app failed liveness probe, will be restarted
+			for _, probeType := range []string{livenessProbe, startupProbe} {
+				if strings.Contains(event.Message, "failed "+strings.ToLower(probeType)+" probe") {
+					killedBy = probeType
+				}
+			}
+		}
+	}
+
+	// The Killing event may be missing, but a SIGKILL or SIGTERM after liveness failures is a probe kill
+	if killedBy == "" && (terminated.ExitCode == 137 || terminated.ExitCode == 143) {
+		for _, failure := range failures {
+			if failure.probeType == livenessProbe || failure.probeType == startupProbe {
+				killedBy = failure.probeType
+			}
+		}
+	}
+	return failures, killedBy
+}
+
+// printProbeVerdict returns the verdict of the probe which killed the terminated container, with the failures
+// observed in the events, which may be fewer than the failure threshold when the events expired or were not recorded.
+func printProbeVerdict(probeType string, container v1.Container, terminated *v1.ContainerStateTerminated, failures []probeFailure) string {
+	probe := container.LivenessProbe
+	if probeType == startupProbe {
+		probe = container.StartupProbe
+	}
+	failureThreshold := int32(3)
+	if probe != nil && probe.FailureThreshold > 0 {
+		failureThreshold = probe.FailureThreshold
+	}
+	observed, exact := countProbeFailures(probeType, terminated, failures)
+	noun := "failures"
+	if observed == 1 {
+		noun = "failure"
+	}
+	switch {
+	case observed == 0:
+		return fmt.Sprintf("killed by %s probe, no failures observed, failure threshold %d", strings.ToLower(probeType), failureThreshold)
+	case !exact:
+		return fmt.Sprintf("killed by %s probe after at least %d %s (aggregated events), failure threshold %d", strings.ToLower(probeType), observed, noun, failureThreshold)
+	}
+	return fmt.Sprintf("killed by %s probe after %d %s, failure threshold %d", strings.ToLower(probeType), observed, noun, failureThreshold)
+}
+
+// countProbeFailures counts the failures of the probe during the life of the terminated container. An aggregated
+// event counts all of its repeats only if it is within that life, otherwise it spans several container lifetimes
+// and counts once, in which case the count is a lower bound and exact is false.
+func countProbeFailures(probeType string, terminated *v1.ContainerStateTerminated, failures []probeFailure) (count int32, exact bool) {
+	exact = true
+	for _, failure := range failures {
+		if failure.probeType != probeType {
+			continue
+		}
+		event := failure.event
+		firstTimestamp := event.FirstTimestamp
+		if firstTimestamp.IsZero() {
+			firstTimestamp = event.LastTimestamp
+		}
+		if event.Count <= 1 {
+			count++
+			continue
+		}
+		if firstTimestamp.Before(&terminated.StartedAt) || terminated.FinishedAt.Before(&event.LastTimestamp) {
+			count++
+			exact = false
+			continue
+		}
+		count += event.Count
+	}
+	return count, exact
+}
+
+// describeProbes describes the probes of the container and the probe failures.
+func describeProbes(container v1.Container, failures []probeFailure) (string, error) {
+	return tabbedString(func(out io.Writer) error {
+		w := describe.NewPrefixWriter(out)
+		probes := []struct {
+			probeType string
+			probe     *v1.Probe
+		}{
+			{livenessProbe, container.LivenessProbe},
+			{readinessProbe, container.ReadinessProbe},
+			{startupProbe, container.StartupProbe},
+		}
+		for _, p := range probes {
+			if p.probe != nil {
+				w.Write(describe.LEVEL_0, "%s:\t%s\n", p.probeType, describeProbe(p.probe))
+			}
+		}
+		if len(failures) > 0 {
+			w.Write(describe.LEVEL_0, "Failures:\n")
+		}
+		for _, failure := range failures {
+			count := ""
+			if failure.event.Count > 1 {
+				count = fmt.Sprintf(" (x%d)", failure.event.Count)
+			}
+			w.Write(describe.LEVEL_1, "%s%s, %s\n", failure.event.LastTimestamp.Time.Format(time.RFC1123Z), count, failure.event.Message)
+		}
+		return nil
+	})
+}
+
+// describeProbe describes a probe in the same format as kubectl describe.
+func describeProbe(probe *v1.Probe) string {
+	attrs := fmt.Sprintf("delay=%ds timeout=%ds period=%ds #success=%d #failure=%d", probe.InitialDelaySeconds, probe.TimeoutSeconds, probe.PeriodSeconds, probe.SuccessThreshold, probe.FailureThreshold)
+	switch {
+	case probe.Exec != nil:
+		return fmt.Sprintf("exec %v %s", probe.Exec.Command, attrs)
+	case probe.HTTPGet != nil:
+		url := &url.URL{}
+		url.Scheme = strings.ToLower(string(probe.HTTPGet.Scheme))
+		if len(probe.HTTPGet.Port.String()) > 0 {
+			url.Host = net.JoinHostPort(probe.HTTPGet.Host, probe.HTTPGet.Port.String())
+		} else {
+			url.Host = probe.HTTPGet.Host
+		}
+		url.Path = probe.HTTPGet.Path
+		return fmt.Sprintf("http-get %s %s", url.String(), attrs)
+	case probe.TCPSocket != nil:
+		return fmt.Sprintf("tcp-socket %s:%s %s", probe.TCPSocket.Host, probe.TCPSocket.Port.String(), attrs)
+	case probe.GRPC != nil:
+		service := ""
+		if probe.GRPC.Service != nil {
+			service = *probe.GRPC.Service
+		}
+		return fmt.Sprintf("grpc <pod>:%d %s %s", probe.GRPC.Port, service, attrs)
+	}
+	return fmt.Sprintf("unknown %s", attrs)
+}
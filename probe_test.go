@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestProbeVerdict(t *testing.T) {
+	startedAt := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	finishedAt := startedAt.Add(10 * time.Minute)
+	status := v1.ContainerStatus{
+		Name: "app",
+		LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+			ExitCode:   137,
+			StartedAt:  metav1.NewTime(startedAt),
+			FinishedAt: metav1.NewTime(finishedAt),
+		}},
+	}
+	container := v1.Container{Name: "app", LivenessProbe: &v1.Probe{FailureThreshold: 3}}
+	event := func(reason, message string, count int32, firstTimestamp, lastTimestamp time.Time) v1.Event {
+		return v1.Event{
+			InvolvedObject: v1.ObjectReference{FieldPath: "spec.containers{app}"},
+			Reason:         reason,
+			Message:        message,
+			Count:          count,
+			FirstTimestamp: metav1.NewTime(firstTimestamp),
+			LastTimestamp:  metav1.NewTime(lastTimestamp),
+		}
+	}
+	killing := event("Killing", "Container app failed liveness probe, will be restarted", 1, finishedAt, finishedAt)
+
+	tests := []struct {
+		name   string
+		events []v1.Event
+		want   string
+	}{
+		{
+			"failures in the container life",
+			[]v1.Event{event("Unhealthy", "Liveness probe failed: timeout", 3, finishedAt.Add(-time.Minute), finishedAt), killing},
+			"killed by liveness probe after 3 failures, failure threshold 3",
+		},
+		{
+			// The same aggregated event was also counted before the previous restarts
+			"aggregated event of several container lifetimes",
+			[]v1.Event{event("Unhealthy", "Liveness probe failed: timeout", 57, startedAt.Add(-time.Hour), finishedAt), killing},
+			"killed by liveness probe after at least 1 failure (aggregated events), failure threshold 3",
+		},
+		{
+			"no probe failure events",
+			[]v1.Event{killing},
+			"killed by liveness probe, no failures observed, failure threshold 3",
+		},
+		{
+			// A SIGKILL after liveness failures without Killing event
+			"SIGKILL after liveness failures",
+			[]v1.Event{event("Unhealthy", "Liveness probe failed: timeout", 1, finishedAt, finishedAt)},
+			"killed by liveness probe after 1 failure, failure threshold 3",
+		},
+		{
+			"readiness failures only",
+			[]v1.Event{event("Unhealthy", "Readiness probe failed: timeout", 5, startedAt, finishedAt)},
+			"",
+		},
+		{
+			"failures of a previous container",
+			[]v1.Event{event("Unhealthy", "Liveness probe failed: timeout", 3, startedAt.Add(-time.Hour), startedAt.Add(-time.Minute))},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict, _, err := describeProbeFailures(status, container, tt.events)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if verdict != tt.want {
+				t.Errorf("describeProbeFailures() verdict = %q, want %q", verdict, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribeProbe(t *testing.T) {
+	service := "health"
+	tests := []struct {
+		name  string
+		probe v1.ProbeHandler
+		want  string
+	}{
+		{"exec", v1.ProbeHandler{Exec: &v1.ExecAction{Command: []string{"cat", "/tmp/healthy"}}}, "exec [cat /tmp/healthy]"},
+		{"http", v1.ProbeHandler{HTTPGet: &v1.HTTPGetAction{Path: "/healthz", Port: intstr.FromInt(8080), Scheme: v1.URISchemeHTTP}}, "http-get http://:8080/healthz"},
+		{"tcp", v1.ProbeHandler{TCPSocket: &v1.TCPSocketAction{Port: intstr.FromString("grpc")}}, "tcp-socket :grpc"},
+		{"grpc", v1.ProbeHandler{GRPC: &v1.GRPCAction{Port: 9090, Service: &service}}, "grpc <pod>:9090 health"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe := &v1.Probe{ProbeHandler: tt.probe, TimeoutSeconds: 1, PeriodSeconds: 10, SuccessThreshold: 1, FailureThreshold: 3}
+			want := tt.want + " delay=0s timeout=1s period=10s #success=1 #failure=3"
+			if got := describeProbe(probe); got != want {
+				t.Errorf("describeProbe() = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/klog/v2"
+)
+
+// recoveryCheckInterval is the interval to check whether the alerted containers recovered.
+const recoveryCheckInterval = 30 * time.Second
+
+// RecoveryTracker tracks the alerted containers until they are Running and Ready for the stable period.
+type RecoveryTracker struct {
+	Enabled      bool
+	StablePeriod time.Duration
+	mu           sync.Mutex
+	// alerts stores the alerted containers, key: Namespace/podName/containerName
+	alerts map[string]*recoveryAlert
+}
+
+// recoveryAlert is an alerted container, the incident starts at its first alerted restart.
+type recoveryAlert struct {
+	namespace    string
+	pod          string
+	podUID       types.UID
+	container    string
+	thread       SlackThread
+	startTime    time.Time
+	alertTime    time.Time
+	restartCount int32 // The restart count before the incident
+	lastRestarts int32 // The last seen restart count
+}
+
+// NewRecoveryTracker creates the RecoveryTracker from the environment variables.
+func NewRecoveryTracker() *RecoveryTracker {
+	stableSeconds, err := strconv.Atoi(os.Getenv("RECOVERY_STABLE_SECONDS"))
+	if err != nil {
+		stableSeconds = 300
+		klog.Warningf("Environment variable RECOVERY_STABLE_SECONDS is not set, default: %d\n", stableSeconds)
+	}
+	tracker := &RecoveryTracker{
+		Enabled:      os.Getenv("NOTIFY_RECOVERY") == "true",
+		StablePeriod: time.Duration(stableSeconds) * time.Second,
+		alerts:       make(map[string]*recoveryAlert),
+	}
+	klog.Infof("Recovery notifications: enabled: %v, stable period: %s\n", tracker.Enabled, tracker.StablePeriod)
+	return tracker
+}
+
+// trackAlert tracks the alerted container, the incident of a container already tracked goes on.
+func (tracker *RecoveryTracker) trackAlert(pod *v1.Pod, status v1.ContainerStatus, thread SlackThread) {
+	if !tracker.Enabled {
+		return
+	}
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	key := pod.Namespace + "/" + pod.Name + "/" + status.Name
+	if alert, ok := tracker.alerts[key]; ok && alert.podUID == pod.UID {
+		alert.lastRestarts = status.RestartCount
+		return
+	}
+	startTime := time.Now()
+	if terminated := status.LastTerminationState.Terminated; terminated != nil && !terminated.FinishedAt.IsZero() {
+		startTime = terminated.FinishedAt.Time
+	}
+	tracker.alerts[key] = &recoveryAlert{
+		namespace:    pod.Namespace,
+		pod:          pod.Name,
+		podUID:       pod.UID,
+		container:    status.Name,
+		thread:       thread,
+		startTime:    startTime,
+		alertTime:    time.Now(),
+		restartCount: status.RestartCount - 1,
+		lastRestarts: status.RestartCount,
+	}
+}
+
+// dueRecovery is a recovery to notify, the alert is a copy taken under the tracker lock.
+type dueRecovery struct {
+	key         string
+	tracked     *recoveryAlert
+	alert       recoveryAlert
+	recovery    string
+	recoveredAt time.Time
+}
+
+// checkRecoveries sends the recovery notifications of the alerted containers which are Running and Ready
+// for the stable period, or whose pods were deleted or replaced. The notifications are sent without holding
+// the tracker lock, so that the alerts are not blocked by Slack.
+func (c *Controller) checkRecoveries() {
+	tracker := c.recoveries
+	for _, due := range c.getDueRecoveries() {
+		if err := c.sendRecovery(&due.alert, due.recovery, due.recoveredAt); err != nil {
+			// Retried at the next check
+			continue
+		}
+		tracker.mu.Lock()
+		// The alert is kept if the container restarted or was alerted again while sending
+		if alert, ok := tracker.alerts[due.key]; ok && alert == due.tracked && alert.lastRestarts == due.alert.lastRestarts {
+			delete(tracker.alerts, due.key)
+		}
+		tracker.mu.Unlock()
+	}
+}
+
+// getDueRecoveries returns the alerted containers which recovered.
+func (c *Controller) getDueRecoveries() []dueRecovery {
+	tracker := c.recoveries
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	var out []dueRecovery
+	for key, alert := range tracker.alerts {
+		pod, err := c.podInformer.Lister().Pods(alert.namespace).Get(alert.pod)
+		if err != nil && !errors.IsNotFound(err) {
+			klog.Errorf("Failed while getting %s/%s from the cache: %v", alert.namespace, alert.pod, err)
+			continue
+		}
+
+		var recovery string
+		recoveredAt := time.Now()
+		if pod == nil || pod.UID != alert.podUID {
+			recovery = "Pod was deleted or replaced"
+		} else {
+			status, ok := getContainerStatus(pod, alert.container)
+			if !ok {
+				continue
+			}
+			alert.lastRestarts = status.RestartCount
+			running := status.State.Running
+			if running == nil || !status.Ready {
+				continue
+			}
+			recoveredAt = running.StartedAt.Time
+			for _, condition := range pod.Status.Conditions {
+				if condition.Type == v1.PodReady && condition.Status == v1.ConditionTrue && condition.LastTransitionTime.After(recoveredAt) {
+					recoveredAt = condition.LastTransitionTime.Time
+				}
+			}
+			if time.Since(recoveredAt) < tracker.StablePeriod {
+				continue
+			}
+			recovery = fmt.Sprintf("Container `%s` is Running and Ready for `%s`", alert.container, duration.HumanDuration(time.Since(recoveredAt)))
+		}
+
+		out = append(out, dueRecovery{key: key, tracked: alert, alert: *alert, recovery: recovery, recoveredAt: recoveredAt})
+	}
+	return out
+}
+
+// sendRecovery sends the recovery notification as a reply of the alert in bot mode, or a follow-up in webhook mode.
+func (c *Controller) sendRecovery(alert *recoveryAlert, recovery string, recoveredAt time.Time) error {
+	timeToRecover := recoveredAt.Sub(alert.startTime)
+	if timeToRecover < 0 {
+		timeToRecover = 0
+	}
+	text := fmt.Sprintf("• Recovery: %s\n", recovery) +
+		fmt.Sprintf("• Time to Recover: `%s`\n", duration.HumanDuration(timeToRecover)) +
+		fmt.Sprintf("• Restarts During Incident: `%d`\n", alert.lastRestarts-alert.restartCount)
+	if alert.thread.Timestamp == "" {
+		text += fmt.Sprintf("• Alerted: `%s` (%s ago)\n", alert.alertTime.Format(time.RFC1123Z), duration.HumanDuration(time.Since(alert.alertTime)))
+	}
+
+	msg := SlackMessage{
+		Title:  fmt.Sprintf("*Pod recovered!*\n*cluster: `%s`, pod: `%s`, namespace: `%s`*", c.slack.ClusterName, alert.pod, alert.namespace),
+		Text:   text,
+		Footer: fmt.Sprintf("%s, %s, %s", c.slack.ClusterName, alert.pod, alert.namespace),
+		Color:  "#2EB67D",
+	}
+	_, err := c.slack.sendToThread(msg, alert.thread)
+	return err
+}
+
+// getContainerStatus returns the status of the container of the pod.
+func getContainerStatus(pod *v1.Pod, containerName string) (v1.ContainerStatus, bool) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == containerName {
+			return status, true
+		}
+	}
+	return v1.ContainerStatus{}, false
+}
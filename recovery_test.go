@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newRecoveryPod returns a pod whose app container restarted 10 minutes ago, and is Running since 5 minutes.
+func newRecoveryPod(ready bool) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-0", UID: types.UID("uid-1")},
+		Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{{
+			Name:                 "app",
+			RestartCount:         3,
+			Ready:                ready,
+			State:                v1.ContainerState{Running: &v1.ContainerStateRunning{StartedAt: metav1.NewTime(time.Now().Add(-5 * time.Minute))}},
+			LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{FinishedAt: metav1.NewTime(time.Now().Add(-10 * time.Minute))}},
+		}}},
+	}
+}
+
+func newRecoveryTestController(t *testing.T, status int) (*Controller, *testWebhook) {
+	podInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Pods()
+	slack, webhook := newTestSlack(t, status)
+	recoveries := &RecoveryTracker{Enabled: true, StablePeriod: time.Minute, alerts: make(map[string]*recoveryAlert)}
+	return &Controller{slack: slack, recoveries: recoveries, podInformer: podInformer}, webhook
+}
+
+func TestCheckRecoveries(t *testing.T) {
+	c, webhook := newRecoveryTestController(t, http.StatusOK)
+	pod := newRecoveryPod(false)
+	indexer := c.podInformer.Informer().GetIndexer()
+	if err := indexer.Add(pod); err != nil {
+		t.Fatal(err)
+	}
+	c.recoveries.trackAlert(pod, pod.Status.ContainerStatuses[0], SlackThread{})
+
+	// The container is not Ready yet
+	c.checkRecoveries()
+	if sent := webhook.sent(); len(sent) != 0 {
+		t.Fatalf("checkRecoveries() sent %d messages before the container is Ready", len(sent))
+	}
+
+	// The incident goes on with a second restart
+	pod = newRecoveryPod(true)
+	pod.Status.ContainerStatuses[0].RestartCount = 4
+	c.recoveries.trackAlert(pod, pod.Status.ContainerStatuses[0], SlackThread{})
+	if err := indexer.Update(pod); err != nil {
+		t.Fatal(err)
+	}
+	c.checkRecoveries()
+	c.checkRecoveries()
+	sent := webhook.sent()
+	if len(sent) != 1 {
+		t.Fatalf("checkRecoveries() sent %d messages, want 1", len(sent))
+	}
+	if want := "*Pod recovered!*\n*cluster: `test`, pod: `web-0`, namespace: `default`*"; sent[0].Title != want {
+		t.Errorf("recovery title = %q, want %q", sent[0].Title, want)
+	}
+	for _, want := range []string{"Container `app` is Running and Ready for `5m", "• Time to Recover: `5m", "• Restarts During Incident: `2`\n", "• Alerted: "} {
+		if !strings.Contains(sent[0].Text, want) {
+			t.Errorf("recovery text = %q, want %q", sent[0].Text, want)
+		}
+	}
+	if len(c.recoveries.alerts) != 0 {
+		t.Errorf("checkRecoveries() kept the recovered alert")
+	}
+}
+
+func TestCheckRecoveriesPodReplaced(t *testing.T) {
+	c, webhook := newRecoveryTestController(t, http.StatusOK)
+	pod := newRecoveryPod(false)
+	c.recoveries.trackAlert(pod, pod.Status.ContainerStatuses[0], SlackThread{})
+	replaced := newRecoveryPod(false)
+	replaced.UID = types.UID("uid-2")
+	if err := c.podInformer.Informer().GetIndexer().Add(replaced); err != nil {
+		t.Fatal(err)
+	}
+
+	c.checkRecoveries()
+	sent := webhook.sent()
+	if len(sent) != 1 || !strings.Contains(sent[0].Text, "• Recovery: Pod was deleted or replaced\n") {
+		t.Errorf("checkRecoveries() sent %v, want the replaced pod recovery", sent)
+	}
+}
+
+func TestCheckRecoveriesSendFailure(t *testing.T) {
+	c, _ := newRecoveryTestController(t, http.StatusInternalServerError)
+	pod := newRecoveryPod(true)
+	if err := c.podInformer.Informer().GetIndexer().Add(pod); err != nil {
+		t.Fatal(err)
+	}
+	c.recoveries.trackAlert(pod, pod.Status.ContainerStatuses[0], SlackThread{})
+
+	// The recovery is retried at the next check
+	c.checkRecoveries()
+	if len(c.recoveries.alerts) != 1 {
+		t.Errorf("checkRecoveries() dropped the alert although the recovery was not sent")
+	}
+}
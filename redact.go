@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// maxRedactLineBytes limits the line redacted at a time in the streams, longer lines are redacted in chunks.
+	maxRedactLineBytes = 64 * 1024
+	// redactedEnvValue replaces the values of the env variables in the pod and workload YAML.
+	redactedEnvValue = "[REDACTED:env]"
+	// lastAppliedConfigAnnotation holds the applied manifest, including the env values.
+	lastAppliedConfigAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+)
+
+// RedactionRule replaces the matches of a regular expression before the messages are sent.
+type RedactionRule struct {
+	Name        string `json:"name"`
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement,omitempty"` // Supports $1 style group references, default: [REDACTED:<name>]
+}
+
+type redactionRule struct {
+	name        string
+	regexp      *regexp.Regexp
+	replacement string
+	// validate filters false positives of the matches, nil to accept all matches
+	validate func(match string) bool
+}
+
+// Redactor removes secrets and personal data from logs, event messages and termination messages.
+type Redactor struct {
+	Enabled bool
+	rules   []redactionRule
+}
+
+var builtinRedactionRules = []redactionRule{
+	{
+		name:   "jwt",
+		regexp: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+	},
+	{
+		name:   "aws-access-key-id",
+		regexp: regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`),
+	},
+	{
+		name:        "aws-secret-access-key",
+		regexp:      regexp.MustCompile(`(?i)(aws_?secret_?access_?key["']?\s*[:=]\s*["']?)[A-Za-z0-9/+=]{40}`),
+		replacement: "${1}[REDACTED:aws-secret-access-key]",
+	},
+	{
+		name:        "bearer-token",
+		regexp:      regexp.MustCompile(`(?i)\b(bearer\s+)[A-Za-z0-9\-._~+/]{8,}=*`),
+		replacement: "${1}[REDACTED:bearer-token]",
+	},
+	{
+		name:        "password",
+		regexp:      regexp.MustCompile(`(?i)\b((password|passwd|pwd|secret|token|api_?key)["']?\s*[:=]\s*["']?)[^\s"',;&]{4,}`),
+		replacement: "${1}[REDACTED:password]",
+	},
+	{
+		name:   "email",
+		regexp: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`),
+	},
+	{
+		name:     "card-number",
+		regexp:   regexp.MustCompile(`\b(4|5[1-5]|2[2-7]|3[47]|6)(\d[ -]?){11,17}\d\b`),
+		validate: isLuhnValid,
+	},
+}
+
+func isRedactionEnabled() bool {
+	return os.Getenv("REDACTION_ENABLED") != "false"
+}
+
+// NewRedactor creates a Redactor with the built-in rules followed by the user rules.
+func NewRedactor(rules []RedactionRule) (Redactor, error) {
+	redactor := Redactor{Enabled: isRedactionEnabled()}
+	redactor.rules = append(redactor.rules, builtinRedactionRules...)
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return redactor, fmt.Errorf("redaction rule name is required")
+		}
+		ruleRegexp, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return redactor, fmt.Errorf("redaction rule %s has invalid pattern: %v", rule.Name, err)
+		}
+		redactor.rules = append(redactor.rules, redactionRule{name: rule.Name, regexp: ruleRegexp, replacement: rule.Replacement})
+	}
+	klog.Infof("Redaction: enabled: %v, rules: %d\n", redactor.Enabled, len(redactor.rules))
+	return redactor, nil
+}
+
+// redact returns the text with the secrets replaced, and the number of redactions.
+func (redactor Redactor) redact(text string) (string, int) {
+	if !redactor.Enabled || text == "" {
+		return text, 0
+	}
+
+	count := 0
+	for _, rule := range redactor.rules {
+		replacement := rule.replacement
+		if replacement == "" {
+			replacement = fmt.Sprintf("[REDACTED:%s]", rule.name)
+		}
+		text = rule.regexp.ReplaceAllStringFunc(text, func(match string) string {
+			if rule.validate != nil && !rule.validate(match) {
+				return match
+			}
+			count++
+			return rule.regexp.ReplaceAllString(match, replacement)
+		})
+	}
+	return text, count
+}
+
+// redactAll redacts the texts in place, and returns the total number of redactions.
+func (redactor Redactor) redactAll(texts ...*string) int {
+	count := 0
+	for _, text := range texts {
+		var n int
+		*text, n = redactor.redact(*text)
+		count += n
+	}
+	return count
+}
+
+// redactStream copies the redacted lines of the reader to the writer, so large logs are redacted without being
+// held in memory. It returns the number of redactions.
+func (redactor Redactor) redactStream(out io.Writer, in io.Reader) (int, error) {
+	if !redactor.Enabled {
+		_, err := io.Copy(out, in)
+		return 0, err
+	}
+	count := 0
+	reader := bufio.NewReaderSize(in, maxRedactLineBytes)
+	for {
+		line, err := reader.ReadSlice('\n')
+		if len(line) > 0 {
+			redacted, n := redactor.redact(string(line))
+			count += n
+			if _, err := io.WriteString(out, redacted); err != nil {
+				return count, err
+			}
+		}
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return count, err
+		}
+	}
+}
+
+// redactEnv replaces the env values of the pod, or of the pod template of the workload, as they often hold secrets
+// which the rules cannot recognize, and removes the last applied configuration which contains them too.
+// The env variables from Secrets and ConfigMaps are references and are kept. It returns the number of redactions.
+func (redactor Redactor) redactEnv(obj interface{}) int {
+	if !redactor.Enabled {
+		return 0
+	}
+	var spec *v1.PodSpec
+	var annotations map[string]string
+	switch obj := obj.(type) {
+	case *v1.Pod:
+		spec, annotations = &obj.Spec, obj.Annotations
+	case *appsv1.Deployment:
+		spec, annotations = &obj.Spec.Template.Spec, obj.Annotations
+	case *appsv1.StatefulSet:
+		spec, annotations = &obj.Spec.Template.Spec, obj.Annotations
+	case *appsv1.DaemonSet:
+		spec, annotations = &obj.Spec.Template.Spec, obj.Annotations
+	case *appsv1.ReplicaSet:
+		spec, annotations = &obj.Spec.Template.Spec, obj.Annotations
+	case *batchv1.Job:
+		spec, annotations = &obj.Spec.Template.Spec, obj.Annotations
+	case *batchv1.CronJob:
+		spec, annotations = &obj.Spec.JobTemplate.Spec.Template.Spec, obj.Annotations
+	default:
+		return 0
+	}
+	count := 0
+	if _, ok := annotations[lastAppliedConfigAnnotation]; ok {
+		delete(annotations, lastAppliedConfigAnnotation)
+		count++
+	}
+	redactEnvVars := func(env []v1.EnvVar) {
+		for i := range env {
+			if env[i].Value != "" {
+				env[i].Value = redactedEnvValue
+				count++
+			}
+		}
+	}
+	for i := range spec.InitContainers {
+		redactEnvVars(spec.InitContainers[i].Env)
+	}
+	for i := range spec.Containers {
+		redactEnvVars(spec.Containers[i].Env)
+	}
+	for i := range spec.EphemeralContainers {
+		redactEnvVars(spec.EphemeralContainers[i].Env)
+	}
+	return count
+}
+
+// isLuhnValid validates the card number checksum, ignoring spaces and dashes.
+func isLuhnValid(number string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(number)
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
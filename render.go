@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+// maxSlackTextLength is the length of the Slack attachment text, Slack truncates the text when > 8000 chars.
+const maxSlackTextLength = 7500
+
+// incidentSection is a section of the rendered incident, the title may contain inline code in backticks.
+type incidentSection struct {
+	Title string
+	Value string // An inline value, e.g. the restart reason
+	Body  string // A preformatted block, e.g. the logs
+	// Truncated is set for the logs, which are truncated to fit in the Slack message
+	Truncated bool
+}
+
+// incidentField is a field of the incident header, e.g. the namespace.
+type incidentField struct {
+	Name  string
+	Value string
+}
+
+// getIncidentSections returns the sections of the incident in the order of the Slack message.
+func getIncidentSections(incident *Incident) []incidentSection {
+	switch {
+	case incident.Job != nil:
+		return getJobIncidentSections(incident)
+	case incident.NodeIncident != nil:
+		return getNodeIncidentSections(incident)
+	case incident.Silence != nil:
+		return getSilenceSections(incident.Silence)
+	case incident.Maintenance != nil:
+		return getMaintenanceSections(incident.Maintenance)
+	}
+	var sections []incidentSection
+	if incident.LogErrors != "" {
+		title := "Errors"
+		if incident.Restarted {
+			title = "Errors Before Restart"
+		}
+		sections = append(sections, incidentSection{Title: title, Body: incident.LogErrors})
+	}
+	sections = append(sections, incidentSection{Body: incident.PodStatus})
+	if incident.Restarted {
+		sections = append(sections, incidentSection{Title: "Reason", Value: fmt.Sprintf("%s (ExitCode %d)", incident.Reason, incident.ExitCode)})
+	}
+	if incident.ProbeVerdict != "" {
+		sections = append(sections, incidentSection{Title: "Verdict", Value: incident.ProbeVerdict})
+	}
+	if incident.Probes != "" {
+		sections = append(sections, incidentSection{Title: "Probes", Body: incident.Probes})
+	}
+	if incident.Diagnosis != "" {
+		sections = append(sections, incidentSection{Title: "Diagnosis", Body: incident.Diagnosis})
+	}
+	if incident.MemoryUsage != "" {
+		sections = append(sections, incidentSection{Title: "Memory Usage", Body: incident.MemoryUsage})
+	}
+	sections = append(sections, incidentSection{Title: "Pod Status", Body: incident.ContainerState + incident.Resources})
+
+	if len(incident.Events) == 0 {
+		sections = append(sections, incidentSection{Title: "No Pod Events"})
+	} else {
+		sections = append(sections, incidentSection{Title: "Pod Events", Body: printIncidentEvents(incident.Events)})
+	}
+	sections = append(sections, incidentSection{Title: "Node Status and Events", Body: incident.NodeStatus + printIncidentEvents(incident.NodeEvents)})
+
+	logsTitle := "Pod Logs"
+	if incident.Restarted {
+		logsTitle = "Pod Logs Before Restart"
+	}
+	if incident.Logs == "" {
+		sections = append(sections, incidentSection{Title: "No " + strings.TrimPrefix(logsTitle, "Pod ")})
+	} else {
+		sections = append(sections, incidentSection{Title: logsTitle, Body: incident.Logs, Truncated: true})
+	}
+	for _, sibling := range incident.SiblingLogs {
+		if sibling.Logs == "" {
+			sections = append(sections, incidentSection{Title: fmt.Sprintf("No Logs of Sibling `%s` Before Restart", sibling.Container)})
+			continue
+		}
+		sections = append(sections, incidentSection{Title: fmt.Sprintf("Logs of Sibling `%s` Before Restart", sibling.Container), Body: sibling.Logs})
+	}
+	return append(sections, getRedactionsSections(incident)...)
+}
+
+// getJobIncidentSections returns the sections of a failed Job, the logs of its failed containers share the
+// length left in the Slack message.
+func getJobIncidentSections(incident *Incident) []incidentSection {
+	var sections []incidentSection
+	for _, pod := range incident.Job.FailedPods {
+		for _, container := range pod.Containers {
+			if container.Errors != "" {
+				sections = append(sections, incidentSection{Title: fmt.Sprintf("Errors of `%s/%s`", pod.Name, container.Container), Body: container.Errors})
+			}
+		}
+	}
+	sections = append(sections, incidentSection{Title: "Reason", Value: incident.Reason})
+	if incident.Job.CronJob != "" {
+		sections = append(sections, incidentSection{Title: incident.Job.CronJob})
+	}
+	sections = append(sections, incidentSection{Title: "Job Status", Body: incident.Job.Status})
+	for _, pod := range incident.Job.FailedPods {
+		sections = append(sections, incidentSection{Title: fmt.Sprintf("Failed Pod `%s`", pod.Name), Body: pod.Status})
+		if len(pod.Events) == 0 {
+			sections = append(sections, incidentSection{Title: "No Pod Events"})
+		} else {
+			sections = append(sections, incidentSection{Title: "Pod Events", Body: printIncidentEvents(pod.Events)})
+		}
+	}
+	if len(incident.Job.FailedPods) == 0 {
+		sections = append(sections, incidentSection{Title: "No Failed Pods Found"})
+	}
+	for _, pod := range incident.Job.FailedPods {
+		for _, container := range pod.Containers {
+			if container.Logs == "" {
+				sections = append(sections, incidentSection{Title: fmt.Sprintf("No Logs of `%s/%s`", pod.Name, container.Container)})
+				continue
+			}
+			sections = append(sections, incidentSection{Title: fmt.Sprintf("Logs of `%s/%s`", pod.Name, container.Container), Body: container.Logs, Truncated: true})
+		}
+	}
+	return append(sections, getRedactionsSections(incident)...)
+}
+
+// getNodeIncidentSections returns the sections of a node incident, the node context is best effort.
+func getNodeIncidentSections(incident *Incident) []incidentSection {
+	var sections []incidentSection
+	if incident.NodeStatus == "" {
+		sections = append(sections, incidentSection{Title: "Node Status Unavailable"})
+	} else {
+		sections = append(sections, incidentSection{Title: "Node Status", Body: incident.NodeStatus})
+	}
+	if incident.NodeIncident.Conditions != "" {
+		sections = append(sections, incidentSection{Title: "Node Conditions", Body: incident.NodeIncident.Conditions})
+	}
+	if len(incident.NodeEvents) == 0 {
+		sections = append(sections, incidentSection{Title: "No Node Events"})
+	} else {
+		sections = append(sections, incidentSection{Title: "Node Events", Body: printIncidentEvents(incident.NodeEvents)})
+	}
+	sections = append(sections, incidentSection{Title: "Affected Pods", Body: incident.NodeIncident.AffectedPods})
+	return append(sections, getRedactionsSections(incident)...)
+}
+
+// getSilenceSections returns the sections of an expired silence.
+func getSilenceSections(silence *IncidentSilence) []incidentSection {
+	sections := []incidentSection{
+		{Title: "Matchers", Value: silence.Matchers},
+		{Title: "Duration", Value: duration.HumanDuration(silence.EndsAt.Sub(silence.StartsAt))},
+		{Title: "Ended", Value: silence.EndsAt.Format(time.RFC1123Z)},
+	}
+	if silence.CreatedBy != "" {
+		sections = append(sections, incidentSection{Title: "Created By", Value: silence.CreatedBy})
+	}
+	if silence.Comment != "" {
+		sections = append(sections, incidentSection{Title: "Comment", Value: silence.Comment})
+	}
+	return sections
+}
+
+// getMaintenanceSections returns the sections of a closed maintenance window.
+func getMaintenanceSections(maintenance *IncidentMaintenanceWindow) []incidentSection {
+	return []incidentSection{
+		{Title: "Window", Value: fmt.Sprintf("%s - %s (%s)", maintenance.Start.Format(time.RFC1123Z), maintenance.End.Format(time.RFC1123Z), duration.HumanDuration(maintenance.End.Sub(maintenance.Start)))},
+		{Title: "Top Workloads", Body: maintenance.TopWorkloads},
+		{Title: "Reasons", Body: maintenance.Reasons},
+	}
+}
+
+func getRedactionsSections(incident *Incident) []incidentSection {
+	if incident.Redactions == 0 {
+		return nil
+	}
+	return []incidentSection{{Title: fmt.Sprintf("Redacted `%d` secrets or personal data from logs, events and messages", incident.Redactions)}}
+}
+
+func printIncidentEvents(events []IncidentEvent) (out string) {
+	for _, event := range events {
+		out = out + fmt.Sprintf("%s, %s, %s\n", event.Time, event.Reason, event.Message)
+	}
+	return out
+}
+
+// getIncidentObject returns the kind and name of the incident object, e.g. the pod or the failed Job.
+func getIncidentObject(incident *Incident) (string, string) {
+	switch {
+	case incident.Job != nil:
+		return "job", incident.Job.Name
+	case incident.NodeIncident != nil:
+		return "node", incident.Node
+	case incident.Silence != nil:
+		return "silence", incident.Silence.Name
+	case incident.Maintenance != nil:
+		return "window", incident.Maintenance.Window
+	}
+	return "pod", incident.Pod
+}
+
+// getIncidentHeader returns the fields identifying the incident, and its count for the reports.
+func getIncidentHeader(incident *Incident) []incidentField {
+	kind, name := getIncidentObject(incident)
+	fields := []incidentField{{"cluster", incident.Cluster}, {kind, name}}
+	if incident.Namespace != "" {
+		fields = append(fields, incidentField{"namespace", incident.Namespace})
+	}
+	switch {
+	case incident.NodeIncident != nil:
+		fields = append(fields, incidentField{"restarted pods", strconv.Itoa(incident.NodeIncident.RestartedPods)})
+	case incident.Silence != nil:
+		fields = append(fields, incidentField{"suppressed alerts", strconv.Itoa(incident.Silence.Suppressed)})
+	case incident.Maintenance != nil:
+		fields = append(fields, incidentField{"restarts", strconv.Itoa(incident.Maintenance.Restarts)})
+	}
+	return fields
+}
+
+// renderSlackMessage renders the incident in Slack mrkdwn, the logs are truncated to fit in the Slack message.
+func renderSlackMessage(incident *Incident) SlackMessage {
+	_, name := getIncidentObject(incident)
+	var header []string
+	for _, field := range getIncidentHeader(incident) {
+		header = append(header, fmt.Sprintf("%s: `%s`", field.Name, field.Value))
+	}
+	footer := []string{incident.Cluster, name}
+	if incident.Namespace != "" {
+		footer = append(footer, incident.Namespace)
+	}
+	return SlackMessage{
+		Title:  fmt.Sprintf("*%s*\n*%s*", incident.Title, strings.Join(header, ", ")),
+		Text:   renderSlackText(incident, maxSlackTextLength),
+		Footer: strings.Join(footer, ", "),
+	}
+}
+
+// renderSlackText renders the sections of the incident in Slack mrkdwn, the logs are truncated to share
+// maxLength, 0 for no limit.
+func renderSlackText(incident *Incident, maxLength int) string {
+	sections := getIncidentSections(incident)
+	var length, truncated int
+	for _, section := range sections {
+		if section.Truncated {
+			truncated++
+		} else {
+			length += len(renderSlackSection(section))
+		}
+	}
+
+	var out string
+	for _, section := range sections {
+		if section.Truncated {
+			maxBodyLength := (maxLength - length) / truncated
+			if maxLength > 0 && maxBodyLength > 0 && len(section.Body) > maxBodyLength {
+				// Cut at the start of a character, not in a multi-byte UTF-8 sequence
+				cut := len(section.Body) - maxBodyLength
+				for cut < len(section.Body) && !utf8.RuneStart(section.Body[cut]) {
+					cut++
+				}
+				section.Body = section.Body[cut:]
+			}
+		}
+		out += renderSlackSection(section)
+	}
+	return out
+}
+
+func renderSlackSection(section incidentSection) string {
+	switch {
+	case section.Title == "":
+		return fmt.Sprintf("```%s```\n", section.Body)
+	case section.Value != "":
+		return fmt.Sprintf("• %s: `%s`\n", section.Title, section.Value)
+	case section.Body != "":
+		return fmt.Sprintf("• %s\n```\n%s```\n", section.Title, section.Body)
+	}
+	return fmt.Sprintf("• %s\n", section.Title)
+}
+
+// renderPlainText renders the incident without markup, e.g. for terminals.
+func renderPlainText(out io.Writer, incident *Incident) error {
+	var header []string
+	for _, field := range getIncidentHeader(incident) {
+		header = append(header, field.Name+": "+field.Value)
+	}
+	if incident.Container != "" {
+		header = append(header, "container: "+incident.Container)
+	}
+	fmt.Fprintf(out, "%s\n%s\n\n", incident.Title, strings.Join(header, ", "))
+	for _, section := range getIncidentSections(incident) {
+		title := strings.ReplaceAll(section.Title, "`", "")
+		switch {
+		case title == "":
+			fmt.Fprintf(out, "%s\n", ensureTrailingNewline(section.Body))
+		case section.Value != "":
+			fmt.Fprintf(out, "%s: %s\n", title, section.Value)
+		case section.Body != "":
+			fmt.Fprintf(out, "%s:\n%s\n", title, ensureTrailingNewline(section.Body))
+		default:
+			fmt.Fprintf(out, "%s\n", title)
+		}
+	}
+	return nil
+}
+
+func ensureTrailingNewline(text string) string {
+	if strings.HasSuffix(text, "\n") {
+		return text
+	}
+	return text + "\n"
+}
+
+var incidentHTMLTemplate = template.Must(template.New("incident").Funcs(template.FuncMap{
+	"plain": func(title string) string { return strings.ReplaceAll(title, "`", "") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Incident.Title }}: {{ with .Incident.Namespace }}{{ . }}/{{ end }}{{ .Name }}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+pre { background: #f6f8fa; padding: 1em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>{{ .Incident.Title }}</h1>
+<p>
+{{- range $i, $field := .Header }}{{ if $i }}, {{ end }}{{ $field.Name }}: <code>{{ $field.Value }}</code>{{ end }}
+{{- with .Incident.Container }}, container: <code>{{ . }}</code>{{ end }}</p>
+{{- range .Sections }}
+{{- if not .Title }}
+<pre>{{ .Body }}</pre>
+{{- else if .Value }}
+<p><b>{{ plain .Title }}:</b> <code>{{ .Value }}</code></p>
+{{- else if .Body }}
+<h3>{{ plain .Title }}</h3>
+<pre>{{ .Body }}</pre>
+{{- else }}
+<p><b>{{ plain .Title }}</b></p>
+{{- end }}
+{{- end }}
+</body>
+</html>
+`))
+
+// renderHTML renders the incident as a standalone HTML page.
+func renderHTML(out io.Writer, incident *Incident) error {
+	_, name := getIncidentObject(incident)
+	return incidentHTMLTemplate.Execute(out, struct {
+		Incident *Incident
+		Name     string
+		Header   []incidentField
+		Sections []incidentSection
+	}{incident, name, getIncidentHeader(incident), getIncidentSections(incident)})
+}
+
+// renderJSON renders the incident as indented JSON.
+func renderJSON(out io.Writer, incident *Incident) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(incident)
+}
+
+// incidentRenderers are the renderers of the diagnose command output formats.
+var incidentRenderers = map[string]func(out io.Writer, incident *Incident) error{
+	"text": renderPlainText,
+	"json": renderJSON,
+	"html": renderHTML,
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func newTestPodIncident() *Incident {
+	return &Incident{
+		Title:          "Pod restarted!",
+		Cluster:        "test",
+		Namespace:      "default",
+		Pod:            "web-0",
+		Container:      "app",
+		Restarted:      true,
+		Reason:         "OOMKilled",
+		ExitCode:       137,
+		PodStatus:      "NAME    READY   STATUS    RESTARTS   AGE\nweb-0   1/1     Running   1          5m\n",
+		ContainerState: "Container: app\n",
+		Events:         []IncidentEvent{{Time: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), Reason: "BackOff", Message: "Back-off <restarting>"}},
+		Logs:           "line 1\nline 2\n",
+	}
+}
+
+func TestRenderSlackTextTruncation(t *testing.T) {
+	incident := newTestPodIncident()
+	incident.Logs = strings.Repeat("日本語のログ\n", 2000)
+
+	text := renderSlackText(incident, maxSlackTextLength)
+	// The logs share the length left by the other sections, within the 8000 characters of Slack
+	if len(text) > 8000 {
+		t.Errorf("renderSlackText() length = %d, want <= 8000", len(text))
+	}
+	if !utf8.ValidString(text) {
+		t.Errorf("renderSlackText() cut the logs in a multi-byte character")
+	}
+	// The most recent logs are kept
+	if !strings.HasSuffix(text, "日本語のログ\n```\n") {
+		t.Errorf("renderSlackText() did not keep the end of the logs: %q", text[len(text)-40:])
+	}
+	if untruncated := renderSlackText(incident, 0); !strings.Contains(untruncated, incident.Logs) {
+		t.Errorf("renderSlackText() truncated the logs without limit")
+	}
+}
+
+func TestRenderSlackMessageHeader(t *testing.T) {
+	endsAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		incident   *Incident
+		wantTitle  string
+		wantText   []string
+		wantFooter string
+	}{
+		{
+			"pod",
+			newTestPodIncident(),
+			"*Pod restarted!*\n*cluster: `test`, pod: `web-0`, namespace: `default`*",
+			[]string{"• Reason: `OOMKilled (ExitCode 137)`\n", "• Pod Logs Before Restart\n```\nline 1\nline 2\n```\n"},
+			"test, web-0, default",
+		},
+		{
+			"silence",
+			&Incident{Title: "Silence expired!", Cluster: "test", Silence: &IncidentSilence{
+				Name: "upgrade", Suppressed: 3, Matchers: "namespace=payments", StartsAt: endsAt.Add(-2 * time.Hour), EndsAt: endsAt, Comment: "node upgrade",
+			}},
+			"*Silence expired!*\n*cluster: `test`, silence: `upgrade`, suppressed alerts: `3`*",
+			[]string{"• Matchers: `namespace=payments`\n", "• Duration: `120m`\n", "• Ended: `Mon, 01 Jan 2024 12:00:00 +0000`\n", "• Comment: `node upgrade`\n"},
+			"test, upgrade",
+		},
+		{
+			"maintenance",
+			&Incident{Title: "Maintenance window closed!", Cluster: "test", Namespace: "payments", Maintenance: &IncidentMaintenanceWindow{
+				Window: "deploys", Start: endsAt.Add(-30 * time.Minute), End: endsAt, Restarts: 5, TopWorkloads: "Deployment/api 5\n", Reasons: "Error 5\n",
+			}},
+			"*Maintenance window closed!*\n*cluster: `test`, window: `deploys`, namespace: `payments`, restarts: `5`*",
+			[]string{"• Window: `Mon, 01 Jan 2024 11:30:00 +0000 - Mon, 01 Jan 2024 12:00:00 +0000 (30m)`\n", "• Top Workloads\n```\nDeployment/api 5\n```\n"},
+			"test, deploys, payments",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := renderSlackMessage(tt.incident)
+			if msg.Title != tt.wantTitle {
+				t.Errorf("renderSlackMessage() title = %q, want %q", msg.Title, tt.wantTitle)
+			}
+			for _, want := range tt.wantText {
+				if !strings.Contains(msg.Text, want) {
+					t.Errorf("renderSlackMessage() text = %q, want %q", msg.Text, want)
+				}
+			}
+			if msg.Footer != tt.wantFooter {
+				t.Errorf("renderSlackMessage() footer = %q, want %q", msg.Footer, tt.wantFooter)
+			}
+		})
+	}
+}
+
+func TestIncidentRenderers(t *testing.T) {
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"text", []string{"Pod restarted!\ncluster: test, pod: web-0, namespace: default, container: app\n", "Reason: OOMKilled (ExitCode 137)\n", "Pod Events:\n2024-01-01 10:00:00 +0000 UTC, BackOff, Back-off <restarting>\n"}},
+		{"html", []string{"<title>Pod restarted!: default/web-0</title>", "container: <code>app</code>", "Back-off &lt;restarting&gt;"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out strings.Builder
+			if err := incidentRenderers[tt.format](&out, newTestPodIncident()); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("%s renderer output = %q, want %q", tt.format, out.String(), want)
+				}
+			}
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var out strings.Builder
+		if err := renderJSON(&out, newTestPodIncident()); err != nil {
+			t.Fatal(err)
+		}
+		var incident Incident
+		if err := json.Unmarshal([]byte(out.String()), &incident); err != nil {
+			t.Fatal(err)
+		}
+		if incident.Pod != "web-0" || incident.Logs != "line 1\nline 2\n" || incident.Silence != nil {
+			t.Errorf("renderJSON() = %s", out.String())
+		}
+	})
+}
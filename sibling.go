@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// CollectSiblingLogsKey is the pod annotation to override COLLECT_SIBLING_LOGS, "true" or "false".
+	CollectSiblingLogsKey = "alert-collect-sibling-logs"
+	// maxSiblingLogLength limits the logs length of each sibling container, the end of the logs is kept.
+	maxSiblingLogLength = 1500
+)
+
+// shouldCollectSiblingLogs returns whether the logs of the other containers in the pod should be collected,
+// from the pod annotation or the COLLECT_SIBLING_LOGS environment variable.
+func shouldCollectSiblingLogs(pod *v1.Pod) bool {
+	if collect, ok := pod.GetAnnotations()[CollectSiblingLogsKey]; ok {
+		return collect == "true"
+	}
+	return os.Getenv("COLLECT_SIBLING_LOGS") == "true"
+}
+
+// getSiblingLogs gets the current logs of the other containers in the pod, during the life of the
+// last terminated instance of the restarted container.
+func (c *Controller) getSiblingLogs(pod *v1.Pod, restarted v1.ContainerStatus) []ContainerLogs {
+	terminated := restarted.LastTerminationState.Terminated
+	if terminated == nil || !shouldCollectSiblingLogs(pod) {
+		return nil
+	}
+
+	var out []ContainerLogs
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == restarted.Name {
+			continue
+		}
+		logs, err := c.getContainerLogsBetween(pod, status.Name, terminated.StartedAt.Time, terminated.FinishedAt.Time)
+		if err != nil {
+			klog.Warningf("Failed while getting %s/%s sibling container %s logs: %v", pod.Namespace, pod.Name, status.Name, err)
+			continue
+		}
+		out = append(out, ContainerLogs{Container: status.Name, Logs: logs})
+	}
+	return out
+}
+
+// getContainerLogsBetween gets the last maxSiblingLogLength bytes of the current container logs between
+// the start and the end time.
+func (c *Controller) getContainerLogsBetween(pod *v1.Pod, containerName string, start time.Time, end time.Time) (string, error) {
+	sinceTime := metav1.NewTime(start)
+	logOptions := &v1.PodLogOptions{
+		Container:  containerName,
+		Timestamps: true,
+		SinceTime:  &sinceTime,
+	}
+	rc, err := c.clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, logOptions).Stream(context.TODO())
+	if err != nil {
+		return "", fmt.Errorf("got error while getting logs: %v", err)
+	}
+	defer rc.Close()
+	return readLastBytes(&logsUntilReader{reader: bufio.NewReader(rc), end: end, lineStart: true}, maxSiblingLogLength)
+}
+
+// logsUntilReader reads timestamped logs until the first line after the end time, as the logs have no until option.
+type logsUntilReader struct {
+	reader    *bufio.Reader
+	end       time.Time
+	lineStart bool   // Whether the next read starts a line
+	pending   []byte // The rest of the current line, valid until the next read of the reader
+	done      bool
+}
+
+func (r *logsUntilReader) Read(p []byte) (int, error) {
+	if r.done {
+		return 0, io.EOF
+	}
+	if len(r.pending) == 0 {
+		if r.lineStart && r.isAfterEnd() {
+			r.done = true
+			return 0, io.EOF
+		}
+		var err error
+		r.pending, err = r.reader.ReadSlice('\n')
+		r.lineStart = err == nil
+		if err != nil && err != bufio.ErrBufferFull && len(r.pending) == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// isAfterEnd returns whether the timestamp of the next line is after the end time.
+func (r *logsUntilReader) isAfterEnd() bool {
+	// The timestamp is RFC3339Nano followed by a space
+	prefix, _ := r.reader.Peek(len(time.RFC3339Nano) + 1)
+	i := bytes.IndexByte(prefix, ' ')
+	if i < 0 {
+		return false
+	}
+	t, err := time.Parse(time.RFC3339Nano, string(prefix[:i]))
+	return err == nil && t.After(r.end)
+}
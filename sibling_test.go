@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLogsUntilReader(t *testing.T) {
+	end := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	longLine := strings.Repeat("x", 5000)
+	tests := []struct {
+		name string
+		logs string
+		want string
+	}{
+		{
+			"lines after the end are dropped",
+			"2024-01-01T09:59:58.000000000Z starting\n2024-01-01T10:00:00.000000000Z shutting down\n2024-01-01T10:00:01.000000000Z started again\n",
+			"2024-01-01T09:59:58.000000000Z starting\n2024-01-01T10:00:00.000000000Z shutting down\n",
+		},
+		{
+			"lines longer than the buffer",
+			"2024-01-01T09:59:58.000000000Z " + longLine + "\n2024-01-01T10:00:01.000000000Z started again\n",
+			"2024-01-01T09:59:58.000000000Z " + longLine + "\n",
+		},
+		{
+			"lines without timestamp are kept",
+			"panic: boom\n",
+			"panic: boom\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := &logsUntilReader{reader: bufio.NewReaderSize(strings.NewReader(tt.logs), 64), end: end, lineStart: true}
+			got, err := ioutil.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("logsUntilReader = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSiblingLogs(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-0"},
+		Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{
+			{
+				Name: "app",
+				LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+					StartedAt:  metav1.NewTime(time.Now().Add(-time.Hour)),
+					FinishedAt: metav1.NewTime(time.Now()),
+				}},
+			},
+			{Name: "envoy"},
+		}},
+	}
+	c := &Controller{clientset: fake.NewSimpleClientset(pod)}
+
+	os.Unsetenv("COLLECT_SIBLING_LOGS")
+	if logs := c.getSiblingLogs(pod, pod.Status.ContainerStatuses[0]); logs != nil {
+		t.Errorf("getSiblingLogs() = %v, want none by default", logs)
+	}
+
+	pod.Annotations = map[string]string{CollectSiblingLogsKey: "true"}
+	logs := c.getSiblingLogs(pod, pod.Status.ContainerStatuses[0])
+	if len(logs) != 1 || logs[0].Container != "envoy" || logs[0].Logs != "fake logs" {
+		t.Errorf("getSiblingLogs() = %v, want the envoy logs", logs)
+	}
+	// The container has not restarted yet
+	if logs := c.getSiblingLogs(pod, pod.Status.ContainerStatuses[1]); logs != nil {
+		t.Errorf("getSiblingLogs() = %v, want none without a terminated container", logs)
+	}
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// silenceCheckInterval is the interval to check the expired silences.
+const silenceCheckInterval = time.Minute
+
+// RestartSilenceResource is the cluster-scoped RestartSilence custom resource.
+var RestartSilenceResource = schema.GroupVersionResource{
+	Group:    "restartinfo.airwallex.com",
+	Version:  "v1alpha1",
+	Resource: "restartsilences",
+}
+
+// RestartSilence suppresses the pod restart alerts matching its matchers between its start and end time.
+type RestartSilence struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              RestartSilenceSpec   `json:"spec"`
+	Status            RestartSilenceStatus `json:"status,omitempty"`
+}
+
+type RestartSilenceSpec struct {
+	Matchers  SilenceMatchers `json:"matchers"`
+	StartsAt  *metav1.Time    `json:"startsAt,omitempty"` // Default: the creation time
+	EndsAt    metav1.Time     `json:"endsAt"`
+	CreatedBy string          `json:"createdBy,omitempty"`
+	Comment   string          `json:"comment,omitempty"`
+	Channel   string          `json:"channel,omitempty"` // The Slack channel of the expiry report, default: SLACK_CHANNEL
+}
+
+// SilenceMatchers select the restarts, all of the set matchers should match.
+// Namespace, workload, reason and container are regular expressions matching the whole value.
+type SilenceMatchers struct {
+	Namespace string            `json:"namespace,omitempty"`
+	Workload  string            `json:"workload,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"` // The pod labels
+	Reason    string            `json:"reason,omitempty"` // The termination reason, e.g. OOMKilled
+	Container string            `json:"container,omitempty"`
+}
+
+type RestartSilenceStatus struct {
+	Suppressed int          `json:"suppressed"`           // The number of suppressed alerts
+	ReportedAt *metav1.Time `json:"reportedAt,omitempty"` // The time the expiry was reported
+}
+
+// Silences evaluates the RestartSilences from an informer cache, and counts the suppressed alerts.
+type Silences struct {
+	Enabled  bool
+	client   dynamic.Interface
+	factory  dynamicinformer.DynamicSharedInformerFactory
+	informer informers.GenericInformer
+	mu       sync.Mutex
+	// silences are the valid silences with their compiled matchers, updated by the informer, key: silence UID
+	silences map[types.UID]*compiledSilence
+	// suppressed counts the suppressed alerts, key: silence UID
+	suppressed map[types.UID]int
+	// reported stores the silences whose expiry was reported, in case the status update failed
+	reported map[types.UID]bool
+}
+
+// compiledSilence is a RestartSilence with its compiled matchers.
+type compiledSilence struct {
+	silence  RestartSilence
+	matchers *silenceMatchers
+}
+
+// silenceMatchers are the compiled SilenceMatchers, nil regular expressions match all the values.
+type silenceMatchers struct {
+	namespace *regexp.Regexp
+	workload  *regexp.Regexp
+	labels    map[string]string
+	reason    *regexp.Regexp
+	container *regexp.Regexp
+}
+
+// NewSilences creates the Silences watching the RestartSilences if WATCH_SILENCES is true and the RestartSilence
+// CRD is installed.
+func NewSilences(client dynamic.Interface, discoveryClient discovery.DiscoveryInterface) *Silences {
+	silences := &Silences{
+		Enabled:    os.Getenv("WATCH_SILENCES") == "true",
+		client:     client,
+		silences:   make(map[types.UID]*compiledSilence),
+		suppressed: make(map[types.UID]int),
+		reported:   make(map[types.UID]bool),
+	}
+	if silences.Enabled {
+		// The informer cache would never sync without the CRD, e.g. helm upgrade does not install the new CRDs
+		if err := checkSilenceResource(discoveryClient); err != nil {
+			klog.Warningf("Silences are disabled, the RestartSilence CRD is not available: %v", err)
+			silences.Enabled = false
+		}
+	}
+	if silences.Enabled {
+		silences.factory = dynamicinformer.NewDynamicSharedInformerFactory(client, 0)
+		silences.informer = silences.factory.ForResource(RestartSilenceResource)
+		silences.informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: silences.update,
+			UpdateFunc: func(old interface{}, new interface{}) {
+				silences.update(new)
+			},
+			DeleteFunc: silences.delete,
+		})
+	}
+	klog.Infof("Silences: enabled: %v\n", silences.Enabled)
+	return silences
+}
+
+// checkSilenceResource returns an error if the API server does not serve the RestartSilence resource.
+func checkSilenceResource(discoveryClient discovery.DiscoveryInterface) error {
+	groupVersion := RestartSilenceResource.GroupVersion().String()
+	resources, err := discoveryClient.ServerResourcesForGroupVersion(groupVersion)
+	if err != nil {
+		return err
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Name == RestartSilenceResource.Resource {
+			return nil
+		}
+	}
+	return fmt.Errorf("the resource %s is not found in %s", RestartSilenceResource.Resource, groupVersion)
+}
+
+// update converts and compiles the added or updated RestartSilence, an invalid silence is ignored once.
+func (silences *Silences) update(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	var silence RestartSilence
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &silence); err != nil {
+		klog.Warningf("Ignore: invalid RestartSilence %s: %v", u.GetName(), err)
+		silences.remove(u.GetUID())
+		return
+	}
+	matchers, err := silence.Spec.Matchers.compile()
+	if err != nil {
+		klog.Warningf("Ignore: invalid RestartSilence %s: %v", silence.Name, err)
+		silences.remove(silence.UID)
+		return
+	}
+	silences.mu.Lock()
+	defer silences.mu.Unlock()
+	silences.silences[silence.UID] = &compiledSilence{silence: silence, matchers: matchers}
+}
+
+func (silences *Silences) delete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		silences.remove(u.GetUID())
+	}
+}
+
+func (silences *Silences) remove(uid types.UID) {
+	silences.mu.Lock()
+	defer silences.mu.Unlock()
+	delete(silences.silences, uid)
+}
+
+// list returns the valid RestartSilences sorted by name, with their compiled matchers.
+func (silences *Silences) list() []compiledSilence {
+	silences.mu.Lock()
+	list := make([]compiledSilence, 0, len(silences.silences))
+	for _, silence := range silences.silences {
+		list = append(list, *silence)
+	}
+	silences.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].silence.Name < list[j].silence.Name })
+	return list
+}
+
+// match returns the first active silence matching the restarted container, nil if there is none.
+func (silences *Silences) match(pod *v1.Pod, status v1.ContainerStatus, now time.Time) *RestartSilence {
+	return silences.matchTarget(newPodSilenceTarget(pod, status), now)
+}
+
+// matchJob returns the first active silence matching a failed container of the Job, or the Job itself if it has
+// no failed pods, nil if there is none.
+func (silences *Silences) matchJob(job *batchv1.Job, failedPods []*v1.Pod, now time.Time) *RestartSilence {
+	_, workload := getJobWorkload(job)
+	var targets []silenceTarget
+	for _, pod := range failedPods {
+		for _, status := range pod.Status.ContainerStatuses {
+			if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+				targets = append(targets, silenceTarget{namespace: job.Namespace, workload: workload, labels: pod.Labels, reason: terminated.Reason, container: status.Name})
+			}
+		}
+	}
+	if len(targets) == 0 {
+		// The condition reason without the message, e.g. DeadlineExceeded
+		reason, _ := getJobFailedReason(job)
+		targets = append(targets, silenceTarget{namespace: job.Namespace, workload: workload, labels: job.Spec.Template.Labels, reason: strings.SplitN(reason, ": ", 2)[0]})
+	}
+	for _, target := range targets {
+		if silence := silences.matchTarget(target, now); silence != nil {
+			return silence
+		}
+	}
+	return nil
+}
+
+func (silences *Silences) matchTarget(target silenceTarget, now time.Time) *RestartSilence {
+	if !silences.Enabled {
+		return nil
+	}
+	for _, compiled := range silences.list() {
+		if compiled.silence.isActive(now) && compiled.matchers.matches(target) {
+			return &compiled.silence
+		}
+	}
+	return nil
+}
+
+// suppress counts the alert suppressed by the silence, and persists the count in its status.
+func (silences *Silences) suppress(silence *RestartSilence) {
+	silences.mu.Lock()
+	count := silences.suppressed[silence.UID]
+	if count < silence.Status.Suppressed {
+		count = silence.Status.Suppressed
+	}
+	count++
+	silences.suppressed[silence.UID] = count
+	silences.mu.Unlock()
+
+	silence.Status.Suppressed = count
+	if err := silences.patchStatus(silence); err != nil {
+		klog.Warningf("Failed while updating RestartSilence %s status: %v", silence.Name, err)
+	}
+}
+
+func (silences *Silences) patchStatus(silence *RestartSilence) error {
+	patch, err := json.Marshal(map[string]interface{}{"status": silence.Status})
+	if err != nil {
+		return err
+	}
+	_, err = silences.client.Resource(RestartSilenceResource).Patch(context.TODO(), silence.Name, types.MergePatchType, patch, metav1.PatchOptions{}, "status")
+	return err
+}
+
+// startsAt returns the start time of the silence, its creation time if not set.
+func (silence *RestartSilence) startsAt() time.Time {
+	if silence.Spec.StartsAt != nil {
+		return silence.Spec.StartsAt.Time
+	}
+	return silence.CreationTimestamp.Time
+}
+
+// isActive returns whether the silence is between its start and end time.
+func (silence *RestartSilence) isActive(now time.Time) bool {
+	return !now.Before(silence.startsAt()) && now.Before(silence.Spec.EndsAt.Time)
+}
+
+// state returns pending, active or expired.
+func (silence *RestartSilence) state(now time.Time) string {
+	if !now.Before(silence.Spec.EndsAt.Time) {
+		return "expired"
+	}
+	if silence.isActive(now) {
+		return "active"
+	}
+	return "pending"
+}
+
+func (matchers SilenceMatchers) isEmpty() bool {
+	return matchers.Namespace == "" && matchers.Workload == "" && len(matchers.Labels) == 0 && matchers.Reason == "" && matchers.Container == ""
+}
+
+// compile validates the matchers, and compiles the regular expressions matching the whole values.
+func (matchers SilenceMatchers) compile() (*silenceMatchers, error) {
+	if matchers.isEmpty() {
+		return nil, fmt.Errorf("at least one matcher is required")
+	}
+	compiled := &silenceMatchers{labels: matchers.Labels}
+	for _, m := range []struct {
+		pattern string
+		re      **regexp.Regexp
+	}{
+		{matchers.Namespace, &compiled.namespace},
+		{matchers.Workload, &compiled.workload},
+		{matchers.Reason, &compiled.reason},
+		{matchers.Container, &compiled.container},
+	} {
+		if m.pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile("^(?:" + m.pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid matcher %q: %v", m.pattern, err)
+		}
+		*m.re = re
+	}
+	return compiled, nil
+}
+
+// silenceTarget is the restarted container or the failed Job matched by the silences.
+type silenceTarget struct {
+	namespace string
+	workload  string // The Deployment, StatefulSet, DaemonSet, CronJob, ... name
+	labels    map[string]string
+	reason    string
+	container string
+}
+
+func newPodSilenceTarget(pod *v1.Pod, status v1.ContainerStatus) silenceTarget {
+	_, workload := getPodWorkload(pod)
+	var reason string
+	if terminated := status.LastTerminationState.Terminated; terminated != nil {
+		reason = terminated.Reason
+	}
+	return silenceTarget{namespace: pod.Namespace, workload: workload, labels: pod.Labels, reason: reason, container: status.Name}
+}
+
+func (matchers *silenceMatchers) matches(target silenceTarget) bool {
+	for _, m := range []struct {
+		re    *regexp.Regexp
+		value string
+	}{
+		{matchers.namespace, target.namespace},
+		{matchers.workload, target.workload},
+		{matchers.reason, target.reason},
+		{matchers.container, target.container},
+	} {
+		if m.re != nil && !m.re.MatchString(m.value) {
+			return false
+		}
+	}
+	for key, value := range matchers.labels {
+		if target.labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// String prints the set matchers, e.g. namespace=payments, reason=OOMKilled.
+func (matchers SilenceMatchers) String() string {
+	var out string
+	add := func(name, value string) {
+		if value == "" {
+			return
+		}
+		if out != "" {
+			out += ", "
+		}
+		out += name + "=" + value
+	}
+	add("namespace", matchers.Namespace)
+	add("workload", matchers.Workload)
+	keys := make([]string, 0, len(matchers.Labels))
+	for key := range matchers.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		add("label:"+key, matchers.Labels[key])
+	}
+	add("reason", matchers.Reason)
+	add("container", matchers.Container)
+	return out
+}
+
+// checkExpiredSilences reports the suppressed alerts of the silences which expired, once per silence.
+func (c *Controller) checkExpiredSilences() {
+	now := time.Now()
+	for _, compiled := range c.silences.list() {
+		silence := compiled.silence
+		if silence.state(now) != "expired" || silence.Status.ReportedAt != nil {
+			continue
+		}
+		c.silences.mu.Lock()
+		if c.silences.reported[silence.UID] {
+			c.silences.mu.Unlock()
+			continue
+		}
+		if count := c.silences.suppressed[silence.UID]; count > silence.Status.Suppressed {
+			silence.Status.Suppressed = count
+		}
+		c.silences.mu.Unlock()
+
+		incident := &Incident{
+			Title:   "Silence expired!",
+			Cluster: c.slack.ClusterName,
+			Silence: &IncidentSilence{
+				Name:       silence.Name,
+				Suppressed: silence.Status.Suppressed,
+				Matchers:   silence.Spec.Matchers.String(),
+				StartsAt:   silence.startsAt(),
+				EndsAt:     silence.Spec.EndsAt.Time,
+				CreatedBy:  silence.Spec.CreatedBy,
+				Comment:    silence.Spec.Comment,
+			},
+		}
+		if err := c.slack.sendToChannel(renderSlackMessage(incident), silence.Spec.Channel); err != nil {
+			continue
+		}
+		// The count is kept until the expiry is reported, to report it on the next check if the message failed
+		c.silences.mu.Lock()
+		c.silences.reported[silence.UID] = true
+		delete(c.silences.suppressed, silence.UID)
+		c.silences.mu.Unlock()
+
+		reportedAt := metav1.NewTime(now)
+		silence.Status.ReportedAt = &reportedAt
+		if err := c.silences.patchStatus(&silence); err != nil {
+			klog.Errorf("Failed while updating RestartSilence %s status: %v", silence.Name, err)
+		}
+	}
+}
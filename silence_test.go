@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNewSilencesWithoutCRD(t *testing.T) {
+	os.Setenv("WATCH_SILENCES", "true")
+	defer os.Unsetenv("WATCH_SILENCES")
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+	discoveryClient := fake.NewSimpleClientset().Discovery().(*fakediscovery.FakeDiscovery)
+	if silences := NewSilences(client, discoveryClient); silences.Enabled {
+		t.Errorf("NewSilences() enabled the silences without the RestartSilence CRD")
+	}
+
+	discoveryClient.Resources = []*metav1.APIResourceList{{
+		GroupVersion: RestartSilenceResource.GroupVersion().String(),
+		APIResources: []metav1.APIResource{{Name: "restartsilences", Kind: "RestartSilence"}},
+	}}
+	if silences := NewSilences(client, discoveryClient); !silences.Enabled || silences.informer == nil {
+		t.Errorf("NewSilences() did not watch the silences with the RestartSilence CRD")
+	}
+}
+
+func TestSilenceMatchers(t *testing.T) {
+	controller := true
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "payments",
+			Name:            "api-7d4b9c-x2k4p",
+			Labels:          map[string]string{"app": "api", "pod-template-hash": "7d4b9c"},
+			OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "api-7d4b9c", Controller: &controller}},
+		},
+	}
+	status := v1.ContainerStatus{
+		Name:                 "app",
+		LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "OOMKilled"}},
+	}
+	tests := []struct {
+		name     string
+		matchers SilenceMatchers
+		want     bool
+		wantErr  bool
+	}{
+		{"namespace", SilenceMatchers{Namespace: "payments|billing"}, true, false},
+		{"namespace matches the whole value", SilenceMatchers{Namespace: "pay"}, false, false},
+		{"workload and reason", SilenceMatchers{Workload: "api", Reason: "OOMKilled"}, true, false},
+		{"other reason", SilenceMatchers{Workload: "api", Reason: "Error"}, false, false},
+		{"labels", SilenceMatchers{Labels: map[string]string{"app": "api"}}, true, false},
+		{"other labels", SilenceMatchers{Labels: map[string]string{"app": "web"}}, false, false},
+		{"container", SilenceMatchers{Container: "sidecar"}, false, false},
+		{"no matchers", SilenceMatchers{}, false, true},
+		{"invalid pattern", SilenceMatchers{Namespace: "("}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matchers, err := tt.matchers.compile()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := matchers.matches(newPodSilenceTarget(pod, status)); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSilenceState(t *testing.T) {
+	now := time.Now()
+	startsAt := metav1.NewTime(now.Add(time.Hour))
+	tests := []struct {
+		name    string
+		silence RestartSilence
+		want    string
+	}{
+		{"active since the creation", RestartSilence{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(now.Add(-time.Hour))}, Spec: RestartSilenceSpec{EndsAt: metav1.NewTime(now.Add(time.Hour))}}, "active"},
+		{"pending", RestartSilence{Spec: RestartSilenceSpec{StartsAt: &startsAt, EndsAt: metav1.NewTime(now.Add(2 * time.Hour))}}, "pending"},
+		{"expired", RestartSilence{Spec: RestartSilenceSpec{EndsAt: metav1.NewTime(now.Add(-time.Minute))}}, "expired"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.silence.state(now); got != tt.want {
+				t.Errorf("state() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckExpiredSilences(t *testing.T) {
+	silence := RestartSilence{
+		ObjectMeta: metav1.ObjectMeta{Name: "load-test", UID: types.UID("uid-1"), CreationTimestamp: metav1.NewTime(time.Now().Add(-2 * time.Hour))},
+		Spec:       RestartSilenceSpec{Matchers: SilenceMatchers{Namespace: "payments"}, EndsAt: metav1.NewTime(time.Now().Add(-time.Minute))},
+	}
+	matchers, err := silence.Spec.Matchers.compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	silences := &Silences{
+		Enabled:    true,
+		client:     dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+		silences:   map[types.UID]*compiledSilence{silence.UID: {silence: silence, matchers: matchers}},
+		suppressed: map[types.UID]int{silence.UID: 4},
+		reported:   make(map[types.UID]bool),
+	}
+
+	// The suppressed count is kept while the report cannot be sent
+	failing, _ := newTestSlack(t, http.StatusInternalServerError)
+	c := &Controller{slack: failing, silences: silences}
+	c.checkExpiredSilences()
+	if silences.reported[silence.UID] || silences.suppressed[silence.UID] != 4 {
+		t.Fatalf("checkExpiredSilences() reported = %v, suppressed = %d after a failed send", silences.reported[silence.UID], silences.suppressed[silence.UID])
+	}
+
+	slack, webhook := newTestSlack(t, http.StatusOK)
+	c.slack = slack
+	c.checkExpiredSilences()
+	c.checkExpiredSilences()
+	sent := webhook.sent()
+	if len(sent) != 1 {
+		t.Fatalf("checkExpiredSilences() sent %d messages, want 1", len(sent))
+	}
+	if !strings.Contains(sent[0].Title, "silence: `load-test`, suppressed alerts: `4`") {
+		t.Errorf("checkExpiredSilences() title = %q", sent[0].Title)
+	}
+	if !silences.reported[silence.UID] {
+		t.Errorf("checkExpiredSilences() did not record the report")
+	}
+	if _, ok := silences.suppressed[silence.UID]; ok {
+		t.Errorf("checkExpiredSilences() kept the suppressed count after the report")
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/dynamic"
+)
+
+const silenceUsage = `Usage: k8s-pod-restart-info-collector silence <command> [flags]
+
+Commands:
+  create   Create a RestartSilence
+  list     List the RestartSilences
+  expire   Expire a RestartSilence now, its suppressed alerts are reported
+`
+
+// labelsFlag is a repeated key=value flag.
+type labelsFlag map[string]string
+
+func (labels labelsFlag) String() string {
+	var pairs []string
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (labels labelsFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	labels[parts[0]] = parts[1]
+	return nil
+}
+
+// runSilenceCommand runs the silence subcommand, and returns the exit code.
+func runSilenceCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, silenceUsage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "create":
+		err = runSilenceCreate(args[1:], os.Stdout)
+	case "list":
+		err = runSilenceList(args[1:], os.Stdout)
+	case "expire":
+		err = runSilenceExpire(args[1:], os.Stdout)
+	default:
+		fmt.Fprint(os.Stderr, silenceUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// newSilenceClient creates the RestartSilence client from the kubeconfig flag.
+func newSilenceClient(kubeconfig string) (dynamic.ResourceInterface, error) {
+	config, err := buildRestConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return client.Resource(RestartSilenceResource), nil
+}
+
+func runSilenceCreate(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("silence create", flag.ContinueOnError)
+	kubeconfig := addKubeconfigFlag(flags)
+	labels := labelsFlag{}
+	var matchers SilenceMatchers
+	flags.StringVar(&matchers.Namespace, "namespace", "", "regular expression matching the namespace")
+	flags.StringVar(&matchers.Workload, "workload", "", "regular expression matching the workload name")
+	flags.Var(labels, "label", "pod label key=value, can be repeated")
+	flags.StringVar(&matchers.Reason, "reason", "", "regular expression matching the termination reason, e.g. OOMKilled")
+	flags.StringVar(&matchers.Container, "container", "", "regular expression matching the container name")
+	startsAt := flags.String("starts-at", "", "RFC3339 start time, default: now")
+	endsAt := flags.String("ends-at", "", "RFC3339 end time, overrides --duration")
+	silenceDuration := flags.Duration("duration", 2*time.Hour, "duration of the silence")
+	createdBy := flags.String("created-by", os.Getenv("USER"), "creator of the silence")
+	comment := flags.String("comment", "", "reason of the silence (required)")
+	channel := flags.String("channel", "", "Slack channel of the expiry report, default: the collector SLACK_CHANNEL")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if len(labels) > 0 {
+		matchers.Labels = labels
+	}
+	if matchers.isEmpty() {
+		return fmt.Errorf("at least one of --namespace, --workload, --label, --reason or --container is required")
+	}
+	if _, err := matchers.compile(); err != nil {
+		return err
+	}
+	if *comment == "" {
+		return fmt.Errorf("--comment is required")
+	}
+
+	start := time.Now()
+	spec := RestartSilenceSpec{
+		Matchers:  matchers,
+		CreatedBy: *createdBy,
+		Comment:   *comment,
+		Channel:   *channel,
+	}
+	if *startsAt != "" {
+		t, err := time.Parse(time.RFC3339, *startsAt)
+		if err != nil {
+			return fmt.Errorf("invalid --starts-at: %v", err)
+		}
+		start = t
+		spec.StartsAt = &metav1.Time{Time: t}
+	}
+	spec.EndsAt = metav1.NewTime(start.Add(*silenceDuration))
+	if *endsAt != "" {
+		t, err := time.Parse(time.RFC3339, *endsAt)
+		if err != nil {
+			return fmt.Errorf("invalid --ends-at: %v", err)
+		}
+		spec.EndsAt = metav1.NewTime(t)
+	}
+	if !spec.EndsAt.After(start) {
+		return fmt.Errorf("the end time %s is before the start time %s", spec.EndsAt.Format(time.RFC3339), start.Format(time.RFC3339))
+	}
+
+	silence := RestartSilence{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: RestartSilenceResource.GroupVersion().String(),
+			Kind:       "RestartSilence",
+		},
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "silence-"},
+		Spec:       spec,
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&silence)
+	if err != nil {
+		return err
+	}
+	client, err := newSilenceClient(*kubeconfig)
+	if err != nil {
+		return err
+	}
+	created, err := client.Create(context.TODO(), &unstructured.Unstructured{Object: obj}, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "restartsilence/%s created, matchers: %s, ends at: %s\n", created.GetName(), matchers, spec.EndsAt.Format(time.RFC3339))
+	return nil
+}
+
+func runSilenceList(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("silence list", flag.ContinueOnError)
+	kubeconfig := addKubeconfigFlag(flags)
+	all := flags.Bool("all", false, "list the expired silences too")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	client, err := newSilenceClient(*kubeconfig)
+	if err != nil {
+		return err
+	}
+	list, err := client.List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATE\tMATCHERS\tSTARTS\tENDS\tSUPPRESSED\tCREATED BY\tCOMMENT")
+	for _, item := range list.Items {
+		var silence RestartSilence
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &silence); err != nil {
+			fmt.Fprintf(os.Stderr, "Ignore: invalid RestartSilence %s: %v\n", item.GetName(), err)
+			continue
+		}
+		state := silence.state(now)
+		if state == "expired" && !*all {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n", silence.Name, state, silence.Spec.Matchers,
+			printRelativeTime(silence.startsAt(), now), printRelativeTime(silence.Spec.EndsAt.Time, now),
+			silence.Status.Suppressed, silence.Spec.CreatedBy, silence.Spec.Comment)
+	}
+	return w.Flush()
+}
+
+func runSilenceExpire(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("silence expire", flag.ContinueOnError)
+	kubeconfig := addKubeconfigFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected the silence name")
+	}
+	client, err := newSilenceClient(*kubeconfig)
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"endsAt": metav1.Now()},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = client.Patch(context.TODO(), flags.Arg(0), types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "restartsilence/%s expired\n", flags.Arg(0))
+	return nil
+}
+
+// printRelativeTime prints the time relative to now, e.g. "in 2h" or "5m ago".
+func printRelativeTime(t time.Time, now time.Time) string {
+	if t.After(now) {
+		return "in " + duration.HumanDuration(t.Sub(now))
+	}
+	return duration.HumanDuration(now.Sub(t)) + " ago"
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
@@ -13,25 +14,48 @@ import (
 
 type Slack struct {
 	WebhookUrl     string
+	BotToken       string // Bot mode posts with the Slack API instead of the webhook, so alerts can be replied in threads
+	SigningSecret  string // Verifies the interaction requests of the alert buttons in bot mode
 	DefaultChannel string // Slack channel name
 	Username       string // Slack username (will show in slack message)
 	ClusterName    string // Kubernete cluster name (will show in slack message)
 	MuteSeconds    int    // The time to mute duplicate alerts
 	// History stores sent alerts, key: Namespace/podName, value: sentTime
 	History map[string]time.Time
+	client  *slack.Client
+	dryRun  *DryRun // Logs the messages instead of sending them if set
 }
 
 type SlackMessage struct {
 	Title  string
 	Text   string
 	Footer string
+	Color  string // The attachment color, default: #4599DF
+	// Actions are the buttons below the message in interactive mode
+	Actions []SlackAction
+}
+
+// SlackThread identifies a message sent in bot mode, the timestamp is empty in webhook mode.
+type SlackThread struct {
+	Channel   string // The channel name, or the channel ID in bot mode
+	Timestamp string
 }
 
 func NewSlack() Slack {
-	var slackWebhookUrl, slackChannel, slackUsername, clusterName string
+	var slackWebhookUrl, slackBotToken, slackSigningSecret, slackChannel, slackUsername, clusterName string
+
+	dryRun, err := NewDryRun()
+	if err != nil {
+		klog.Exit(err)
+	}
 
-	if slackWebhookUrl = os.Getenv("SLACK_WEBHOOK_URL"); slackWebhookUrl == "" {
-		klog.Exit("Environment variable SLACK_WEBHOOK_URL is not set")
+	slackBotToken = os.Getenv("SLACK_BOT_TOKEN")
+	if slackSigningSecret = os.Getenv("SLACK_SIGNING_SECRET"); slackSigningSecret != "" && slackBotToken == "" {
+		klog.Warning("Environment variable SLACK_SIGNING_SECRET is ignored without SLACK_BOT_TOKEN, the alert buttons require bot mode")
+		slackSigningSecret = ""
+	}
+	if slackWebhookUrl = os.Getenv("SLACK_WEBHOOK_URL"); slackWebhookUrl == "" && slackBotToken == "" && dryRun == nil {
+		klog.Exit("Environment variable SLACK_WEBHOOK_URL or SLACK_BOT_TOKEN is not set")
 	}
 
 	if slackChannel = os.Getenv("SLACK_CHANNEL"); slackChannel == "" {
@@ -55,43 +79,171 @@ func NewSlack() Slack {
 		klog.Warningf("Environment variable MUTE_SECONDS is not set, default: %d\n", muteSeconds)
 	}
 
-	klog.Infof("Slack Info: channel: %s, username: %s, clustername: %s, muteseconds: %d\n", slackChannel, slackUsername, clusterName, muteSeconds)
+	klog.Infof("Slack Info: channel: %s, username: %s, clustername: %s, muteseconds: %d, botmode: %v, interactive: %v\n", slackChannel, slackUsername, clusterName, muteSeconds, slackBotToken != "", slackSigningSecret != "")
 
+	var client *slack.Client
+	if slackBotToken != "" {
+		client = slack.New(slackBotToken)
+	}
 	return Slack{
 		WebhookUrl:     slackWebhookUrl,
+		BotToken:       slackBotToken,
+		SigningSecret:  slackSigningSecret,
+		client:         client,
 		DefaultChannel: slackChannel,
 		Username:       slackUsername,
 		ClusterName:    clusterName,
 		MuteSeconds:    muteSeconds,
 		History:        make(map[string]time.Time),
+		dryRun:         dryRun,
 	}
 }
 
+// isInteractive returns whether the alerts have buttons, which requires bot mode and the signing secret.
+func (s Slack) isInteractive() bool {
+	return s.client != nil && s.SigningSecret != ""
+}
+
 func (s Slack) sendToChannel(msg SlackMessage, slackChannel string) error {
-	channel := s.DefaultChannel
-	if slackChannel != "" {
-		channel = slackChannel
+	_, err := s.sendToThread(msg, SlackThread{Channel: slackChannel})
+	return err
+}
+
+// getChannel returns the channel of the message, the default channel if not set.
+func (s Slack) getChannel(channel string) string {
+	if channel == "" {
+		return s.DefaultChannel
 	}
+	return channel
+}
 
-	attachment := slack.Attachment{
+// sendToThread sends the message as a reply of the thread in bot mode, or to the thread channel in webhook mode,
+// and returns the sent message thread.
+func (s Slack) sendToThread(msg SlackMessage, thread SlackThread) (SlackThread, error) {
+	channel := s.getChannel(thread.Channel)
+	attachment := newAttachment(msg)
+
+	sent := SlackThread{Channel: channel}
+	if s.dryRun != nil {
+		s.dryRun.record(msg, channel, thread.Timestamp)
+		return sent, nil
+	}
+	var err error
+	if s.client != nil {
+		attachments := []slack.Attachment{attachment}
+		if len(msg.Actions) > 0 {
+			attachments = append(attachments, newActionsAttachment(msg.Actions, attachment.Color))
+		}
+		options := []slack.MsgOption{
+			slack.MsgOptionUsername(s.Username),
+			slack.MsgOptionIconEmoji(":kubernetes:"),
+			slack.MsgOptionAttachments(attachments...),
+		}
+		if thread.Timestamp != "" {
+			options = append(options, slack.MsgOptionTS(thread.Timestamp))
+		}
+		sent.Channel, sent.Timestamp, err = s.client.PostMessage(channel, options...)
+	} else {
+		err = slack.PostWebhook(s.WebhookUrl, &slack.WebhookMessage{
+			Username:    s.Username,
+			Channel:     channel,
+			IconEmoji:   ":kubernetes:",
+			Attachments: []slack.Attachment{attachment},
+		})
+	}
+	if err != nil {
+		klog.Errorf("Sending to Slack channel failed with %v", err)
+		return sent, err
+	}
+	klog.Infof("Sent: [%s] to Slack.\n\n", strings.Replace(msg.Title, "\n", " ", -1))
+	return sent, nil
+}
+
+// respond sends the message as the response of a slash command, visible to the channel or to the user only.
+func (s Slack) respond(responseURL string, msg SlackMessage, inChannel bool) error {
+	responseType := slack.ResponseTypeEphemeral
+	if inChannel {
+		responseType = slack.ResponseTypeInChannel
+	}
+	if s.dryRun != nil {
+		// The response URL is a secret, the response type is recorded instead of the channel
+		s.dryRun.record(msg, "response:"+responseType, "")
+		return nil
+	}
+	err := slack.PostWebhook(responseURL, &slack.WebhookMessage{
+		ResponseType: responseType,
+		Attachments:  []slack.Attachment{newAttachment(msg)},
+	})
+	if err != nil {
+		klog.Errorf("Responding to Slack command failed with %v", err)
+		return err
+	}
+	klog.Infof("Responded: [%s] to Slack.\n\n", strings.Replace(msg.Title, "\n", " ", -1))
+	return nil
+}
+
+func newAttachment(msg SlackMessage) slack.Attachment {
+	color := msg.Color
+	if color == "" {
+		color = "#4599DF"
+	}
+	return slack.Attachment{
 		Text:       msg.Text,
 		Pretext:    msg.Title,
 		Footer:     msg.Footer,
 		MarkdownIn: []string{"text", "pretext"},
-		Color:      "#4599DF",
+		Color:      color,
 		Ts:         json.Number(strconv.FormatInt(time.Now().Unix(), 10)),
 	}
+}
+
+// newActionsAttachment creates the attachment with the buttons, below the message attachment.
+func newActionsAttachment(actions []SlackAction, color string) slack.Attachment {
+	elements := make([]slack.BlockElement, 0, len(actions))
+	for _, action := range actions {
+		elements = append(elements, slack.NewButtonBlockElement(action.ID, action.Value,
+			slack.NewTextBlockObject(slack.PlainTextType, action.Text, false, false)))
+	}
+	return slack.Attachment{
+		Color:  color,
+		Blocks: slack.Blocks{BlockSet: []slack.Block{slack.NewActionBlock("actions", elements...)}},
+	}
+}
 
-	err := slack.PostWebhook(s.WebhookUrl, &slack.WebhookMessage{
-		Username:    s.Username,
-		Channel:     channel,
-		IconEmoji:   ":kubernetes:",
-		Attachments: []slack.Attachment{attachment},
+// uploadToThread uploads the content as a file replied in the thread, in bot mode only.
+func (s Slack) uploadToThread(filename, title, content string, thread SlackThread) error {
+	if s.dryRun != nil {
+		s.dryRun.record(SlackMessage{Title: title, Text: content, Footer: filename}, thread.Channel, thread.Timestamp)
+		return nil
+	}
+	if s.client == nil {
+		return fmt.Errorf("uploading files requires bot mode")
+	}
+	_, err := s.client.UploadFile(slack.FileUploadParameters{
+		Content:         content,
+		Filename:        filename,
+		Title:           title,
+		Channels:        []string{thread.Channel},
+		ThreadTimestamp: thread.Timestamp,
 	})
 	if err != nil {
-		klog.Errorf("Sending to Slack channel failed with %v", err)
+		klog.Errorf("Uploading %s to Slack failed with %v", filename, err)
 		return err
 	}
-	klog.Infof("Sent: [%s] to Slack.\n\n", strings.Replace(msg.Title, "\n", " ", -1))
+	klog.Infof("Uploaded: [%s] to Slack.\n", title)
 	return nil
 }
+
+// sendEphemeral sends the text visible to the user only, in bot mode only.
+func (s Slack) sendEphemeral(channel, user, text string) {
+	if s.dryRun != nil {
+		s.dryRun.record(SlackMessage{Title: text}, channel, "")
+		return
+	}
+	if s.client == nil {
+		return
+	}
+	if _, err := s.client.PostEphemeral(channel, user, slack.MsgOptionText(text, false)); err != nil {
+		klog.Errorf("Sending ephemeral message to Slack channel failed with %v", err)
+	}
+}
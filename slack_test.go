@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// testWebhook is a Slack webhook server recording the received messages.
+type testWebhook struct {
+	mu       sync.Mutex
+	messages []SlackMessage
+	channels []string
+}
+
+// newTestSlack creates the Slack sending to a test webhook, which responds with the status.
+func newTestSlack(t *testing.T, status int) (Slack, *testWebhook) {
+	webhook := &testWebhook{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg slack.WebhookMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("invalid webhook message: %v", err)
+		}
+		if status == http.StatusOK {
+			webhook.mu.Lock()
+			for _, attachment := range msg.Attachments {
+				webhook.messages = append(webhook.messages, SlackMessage{Title: attachment.Pretext, Text: attachment.Text, Footer: attachment.Footer})
+				webhook.channels = append(webhook.channels, msg.Channel)
+			}
+			webhook.mu.Unlock()
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return Slack{
+		WebhookUrl:     server.URL,
+		DefaultChannel: "restart-info",
+		ClusterName:    "test",
+		History:        make(map[string]time.Time),
+	}, webhook
+}
+
+// sent returns the received messages.
+func (webhook *testWebhook) sent() []SlackMessage {
+	webhook.mu.Lock()
+	defer webhook.mu.Unlock()
+	return append([]SlackMessage(nil), webhook.messages...)
+}
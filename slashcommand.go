@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/slack-go/slack"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+const (
+	slashCommandUsage = "Usage: `/restartinfo <namespace>/<pod> [container]`"
+	// DefaultContainerKey is the pod annotation of the default container of kubectl, reported by the slash command.
+	DefaultContainerKey = "kubectl.kubernetes.io/default-container"
+)
+
+// SlashCommandChannel allows the /restartinfo slash command in a Slack channel for the matching namespaces.
+type SlashCommandChannel struct {
+	Channel    string   `json:"channel"`    // The channel name or ID
+	Namespaces []string `json:"namespaces"` // Regular expressions matching the whole namespace
+	namespaces []*regexp.Regexp
+}
+
+// SlashCommandAccess is the namespaces allowed per Slack channel, the other channels are not allowed.
+type SlashCommandAccess []SlashCommandChannel
+
+// NewSlashCommandAccess validates the channels of the slash command.
+func NewSlashCommandAccess(channels []SlashCommandChannel) (SlashCommandAccess, error) {
+	for i := range channels {
+		channel := &channels[i]
+		if channel.Channel == "" {
+			return nil, fmt.Errorf("slash command channel %d has no channel", i)
+		}
+		for _, pattern := range channel.Namespaces {
+			re, err := regexp.Compile("^(?:" + pattern + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("slash command channel %s has invalid namespace %q: %v", channel.Channel, pattern, err)
+			}
+			channel.namespaces = append(channel.namespaces, re)
+		}
+		klog.Infof("Slash command: channel: %s, namespaces: %v\n", channel.Channel, channel.Namespaces)
+	}
+	return channels, nil
+}
+
+// isAllowed returns whether the namespace is allowed in the channel, identified by its ID or name.
+func (access SlashCommandAccess) isAllowed(channelID, channelName, namespace string) bool {
+	for _, channel := range access {
+		if channel.Channel != channelID && strings.TrimPrefix(channel.Channel, "#") != channelName {
+			continue
+		}
+		for _, re := range channel.namespaces {
+			if re.MatchString(namespace) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// handleSlashCommand handles /restartinfo <namespace>/<pod> [container], the report is collected on demand
+// for a pod which has not necessarily restarted, and replied in the channel.
+func (c *Controller) handleSlashCommand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, fmt.Sprintf("method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	form, ok := c.readSlackRequest(w, r)
+	if !ok {
+		return
+	}
+	channelID, channelName, user := form.Get("channel_id"), form.Get("channel_name"), form.Get("user_id")
+	responseURL := form.Get("response_url")
+	klog.Infof("Slash command: %s %s in %s by %s\n", form.Get("command"), form.Get("text"), channelName, form.Get("user_name"))
+
+	args := strings.Fields(form.Get("text"))
+	if len(args) == 0 || len(args) > 2 {
+		writeSlashCommandResponse(w, slashCommandUsage)
+		return
+	}
+	namespace, name, err := cache.SplitMetaNamespaceKey(args[0])
+	if err != nil || namespace == "" || name == "" {
+		writeSlashCommandResponse(w, slashCommandUsage)
+		return
+	}
+	if !c.slashCommands.isAllowed(channelID, channelName, namespace) {
+		writeSlashCommandResponse(w, fmt.Sprintf("The namespace `%s` is not allowed in this channel.", namespace))
+		return
+	}
+	pod, err := c.getPodFromIndexer(namespace + "/" + name)
+	if err != nil {
+		writeSlashCommandResponse(w, fmt.Sprintf("The pod `%s/%s` is not found.", namespace, name))
+		return
+	}
+	containerName := getDefaultContainerName(pod)
+	if len(args) == 2 {
+		containerName = args[1]
+	}
+	status, ok := getContainerStatus(pod, containerName)
+	if !ok {
+		writeSlashCommandResponse(w, fmt.Sprintf("The container `%s` of the pod `%s/%s` is not found.", containerName, namespace, name))
+		return
+	}
+
+	// Slack expects the response within 3 seconds, the report is sent to the response URL
+	writeSlashCommandResponse(w, fmt.Sprintf("Collecting the report of `%s/%s` container `%s`...", namespace, name, containerName))
+	go func() {
+		incident, err := c.collectIncident(pod, status)
+		if err != nil {
+			klog.Errorf("Failed while collecting %s/%s report: %v", namespace, name, err)
+			c.slack.respond(responseURL, SlackMessage{Title: fmt.Sprintf("Failed while collecting the report of `%s/%s`: %v", namespace, name, err)}, false)
+			return
+		}
+		incident.Title = "Pod report"
+		msg := renderSlackMessage(incident)
+		msg.Title = fmt.Sprintf("*%s*\n*cluster: `%s`, pod: `%s`, namespace: `%s`, container: `%s`*",
+			incident.Title, incident.Cluster, incident.Pod, incident.Namespace, incident.Container)
+		msg.Footer += fmt.Sprintf(", requested by <@%s>", user)
+		c.slack.respond(responseURL, msg, true)
+	}()
+}
+
+// getDefaultContainerName returns the container of the kubectl default container annotation, or the first container.
+func getDefaultContainerName(pod *v1.Pod) string {
+	if name, ok := pod.GetAnnotations()[DefaultContainerKey]; ok {
+		return name
+	}
+	if len(pod.Spec.Containers) > 0 {
+		return pod.Spec.Containers[0].Name
+	}
+	return ""
+}
+
+// writeSlashCommandResponse writes the immediate response of the slash command, visible to the user only.
+func writeSlashCommandResponse(w http.ResponseWriter, text string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"response_type": slack.ResponseTypeEphemeral, "text": text})
+}
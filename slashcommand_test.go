@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSlashCommandAccess(t *testing.T) {
+	access, err := NewSlashCommandAccess([]SlashCommandChannel{
+		{Channel: "#payments-oncall", Namespaces: []string{"payments|billing"}},
+		{Channel: "C0123", Namespaces: []string{"web-.*"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name                              string
+		channelID, channelName, namespace string
+		want                              bool
+	}{
+		{"channel name", "C9999", "payments-oncall", "billing", true},
+		{"channel ID", "C0123", "web-oncall", "web-prod", true},
+		{"namespace matches the whole value", "C9999", "payments-oncall", "payments-dev", false},
+		{"namespace of another channel", "C0123", "web-oncall", "payments", false},
+		{"other channel", "C4567", "general", "payments", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := access.isAllowed(tt.channelID, tt.channelName, tt.namespace); got != tt.want {
+				t.Errorf("isAllowed(%s, %s, %s) = %v, want %v", tt.channelID, tt.channelName, tt.namespace, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewSlashCommandAccess([]SlashCommandChannel{{Channel: "ops", Namespaces: []string{"("}}}); err == nil {
+		t.Errorf("NewSlashCommandAccess() accepted an invalid namespace pattern")
+	}
+}
+
+func TestHandleSlashCommand(t *testing.T) {
+	const secret = "8f742231b10e8888abcd99yyyzzz85a5"
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "api-0"},
+		Spec:       v1.PodSpec{NodeName: "node-1", Containers: []v1.Container{{Name: "app"}, {Name: "envoy"}}},
+		Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{
+			{Name: "app", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}},
+			{Name: "envoy", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}},
+		}},
+	}
+	clientset := fake.NewSimpleClientset(pod, &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}})
+	filterEventsByUID(clientset)
+	podInformer := informers.NewSharedInformerFactory(clientset, 0).Core().V1().Pods()
+	if err := podInformer.Informer().GetIndexer().Add(pod); err != nil {
+		t.Fatal(err)
+	}
+	access, err := NewSlashCommandAccess([]SlashCommandChannel{{Channel: "#payments-oncall", Namespaces: []string{"payments"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slack, webhook := newTestSlack(t, http.StatusOK)
+	slack.SigningSecret = secret
+	c := &Controller{clientset: clientset, slack: slack, slashCommands: access, podInformer: podInformer, eventsWindow: time.Hour}
+
+	tests := []struct {
+		name        string
+		channelName string
+		text        string
+		want        string
+	}{
+		{"usage", "payments-oncall", "", slashCommandUsage},
+		{"invalid pod", "payments-oncall", "api-0", slashCommandUsage},
+		{"namespace not allowed", "general", "payments/api-0", "The namespace `payments` is not allowed in this channel."},
+		{"pod not found", "payments-oncall", "payments/api-1", "The pod `payments/api-1` is not found."},
+		{"container not found", "payments-oncall", "payments/api-0 worker", "The container `worker` of the pod `payments/api-0` is not found."},
+		{"report", "payments-oncall", "payments/api-0 envoy", "Collecting the report of `payments/api-0` container `envoy`..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{
+				"command":      {"/restartinfo"},
+				"text":         {tt.text},
+				"channel_id":   {"C9999"},
+				"channel_name": {tt.channelName},
+				"user_id":      {"U0001"},
+				"response_url": {slack.WebhookUrl},
+			}
+			w := httptest.NewRecorder()
+			c.handleSlashCommand(w, newSlackRequest(secret, time.Now(), form.Encode()))
+			var response map[string]string
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if response["text"] != tt.want || response["response_type"] != "ephemeral" {
+				t.Errorf("handleSlashCommand() response = %v, want %q", response, tt.want)
+			}
+		})
+	}
+
+	// The report is sent to the response URL once collected
+	deadline := time.Now().Add(5 * time.Second)
+	for len(webhook.sent()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	sent := webhook.sent()
+	if len(sent) != 1 {
+		t.Fatalf("handleSlashCommand() sent %d reports, want 1", len(sent))
+	}
+	if want := "*Pod report*\n*cluster: `test`, pod: `api-0`, namespace: `payments`, container: `envoy`*"; sent[0].Title != want {
+		t.Errorf("report title = %q, want %q", sent[0].Title, want)
+	}
+	if !strings.HasSuffix(sent[0].Footer, ", requested by <@U0001>") {
+		t.Errorf("report footer = %q", sent[0].Footer)
+	}
+}
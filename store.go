@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// The status of the incidents, whether a notification was sent.
+const (
+	IncidentNotified    = "notified"
+	IncidentMuted       = "muted"       // Already sent within MUTE_SECONDS
+	IncidentSuppressed  = "suppressed"  // Suppressed by a node incident
+	IncidentSilenced    = "silenced"    // Suppressed by a RestartSilence
+	IncidentMaintenance = "maintenance" // During a maintenance window
+)
+
+var incidentsBucket = []byte("incidents")
+
+// ErrInvalidIncidentID is returned by Get and SetArchiveURL when the id is not a hexadecimal incident key.
+var ErrInvalidIncidentID = errors.New("invalid incident id")
+
+// IncidentRecord is a restart persisted in the incident store.
+type IncidentRecord struct {
+	ID           string    `json:"id"`
+	Time         time.Time `json:"time"`
+	Cluster      string    `json:"cluster"`
+	Channel      string    `json:"channel"`
+	Namespace    string    `json:"namespace"`
+	WorkloadKind string    `json:"workloadKind"`
+	Workload     string    `json:"workload"`
+	Pod          string    `json:"pod"`
+	Container    string    `json:"container"`
+	Reason       string    `json:"reason"`
+	ExitCode     int32     `json:"exitCode"`
+	Node         string    `json:"node"`
+	Image        string    `json:"image"`
+	RestartCount int32     `json:"restartCount"`
+	Status       string    `json:"status"`
+	ArchiveURL   string    `json:"archiveUrl,omitempty"`
+	// Title and Context are the message title and the full message text without the Slack length limit
+	Title   string `json:"title,omitempty"`
+	Context string `json:"context,omitempty"`
+}
+
+// IncidentFilter selects the incidents, empty fields match all incidents.
+type IncidentFilter struct {
+	Channel   string
+	Namespace string
+	Workload  string
+	Pod       string
+	Container string
+	Reason    string
+	Node      string
+	Status    string
+	Since     time.Time
+	Until     time.Time
+}
+
+// IncidentCount is the number of incidents of a workload, reason or node.
+type IncidentCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// IncidentStore persists the incidents in an embedded BoltDB file.
+type IncidentStore struct {
+	Retention time.Duration
+	db        *bolt.DB
+}
+
+// NewIncidentStore opens the incident store at INCIDENT_DB_PATH, nil is returned if it is not set.
+func NewIncidentStore() (*IncidentStore, error) {
+	path := os.Getenv("INCIDENT_DB_PATH")
+	if path == "" {
+		klog.Info("Incident store: disabled, INCIDENT_DB_PATH is not set\n")
+		return nil, nil
+	}
+
+	retentionDays, err := strconv.Atoi(os.Getenv("INCIDENT_DB_RETENTION_DAYS"))
+	if err != nil {
+		retentionDays = 30
+		klog.Warningf("Environment variable INCIDENT_DB_RETENTION_DAYS is not set, default: %d\n", retentionDays)
+	}
+
+	// The file is locked by the previous collector until it exits
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Minute})
+	if err != nil {
+		return nil, fmt.Errorf("got error while opening incident store %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(incidentsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("got error while creating incident store bucket: %v", err)
+	}
+	klog.Infof("Incident store: path: %s, retention: %d days\n", path, retentionDays)
+	return &IncidentStore{
+		Retention: time.Duration(retentionDays) * 24 * time.Hour,
+		db:        db,
+	}, nil
+}
+
+// incidentKey orders the incidents by time, the sequence number makes the key unique.
+func incidentKey(t time.Time, sequence uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[:8], uint64(t.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], sequence)
+	return key
+}
+
+// Add persists the incident and deletes the incidents older than the retention.
+func (store *IncidentStore) Add(incident *IncidentRecord) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(incidentsBucket)
+		sequence, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := incidentKey(incident.Time, sequence)
+		incident.ID = hex.EncodeToString(key)
+		value, err := json.Marshal(incident)
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(key, value); err != nil {
+			return err
+		}
+
+		if store.Retention <= 0 {
+			return nil
+		}
+		expiry := incidentKey(time.Now().Add(-store.Retention), 0)
+		var expired [][]byte
+		cursor := bucket.Cursor()
+		for k, _ := cursor.First(); k != nil && string(k) < string(expiry); k, _ = cursor.Next() {
+			expired = append(expired, k)
+		}
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// List returns the incidents matching the filter, the newest first, and the total number of the matching incidents.
+func (store *IncidentStore) List(filter IncidentFilter, offset int, limit int) ([]IncidentRecord, int, error) {
+	incidents := []IncidentRecord{}
+	total := 0
+	err := store.forEach(filter, func(incident IncidentRecord) {
+		if total >= offset && len(incidents) < limit {
+			// The context is only returned by Get
+			incident.Context = ""
+			incidents = append(incidents, incident)
+		}
+		total++
+	})
+	return incidents, total, err
+}
+
+// Get returns the incident with the id, nil if it does not exist, or ErrInvalidIncidentID if the id is malformed.
+func (store *IncidentStore) Get(id string) (*IncidentRecord, error) {
+	key, err := hex.DecodeString(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %v", ErrInvalidIncidentID, id, err)
+	}
+	var incident *IncidentRecord
+	err = store.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(incidentsBucket).Get(key)
+		if value == nil {
+			return nil
+		}
+		incident = &IncidentRecord{}
+		return json.Unmarshal(value, incident)
+	})
+	return incident, err
+}
+
+// SetArchiveURL sets the archive URL of the incident, once the archive is uploaded after the alert.
+func (store *IncidentStore) SetArchiveURL(id string, archiveURL string) error {
+	key, err := hex.DecodeString(id)
+	if err != nil {
+		return fmt.Errorf("%w %q: %v", ErrInvalidIncidentID, id, err)
+	}
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(incidentsBucket)
+		value := bucket.Get(key)
+		if value == nil {
+			// Deleted by the retention
+			return nil
+		}
+		var incident IncidentRecord
+		if err := json.Unmarshal(value, &incident); err != nil {
+			return err
+		}
+		incident.ArchiveURL = archiveURL
+		value, err := json.Marshal(incident)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key, value)
+	})
+}
+
+// Top returns the n workloads, reasons or nodes with the most incidents matching the filter.
+func (store *IncidentStore) Top(filter IncidentFilter, by string, n int) ([]IncidentCount, error) {
+	var keyOf func(incident IncidentRecord) string
+	switch by {
+	case "workload":
+		keyOf = func(incident IncidentRecord) string {
+			return incident.Namespace + "/" + incident.WorkloadKind + "/" + incident.Workload
+		}
+	case "reason":
+		keyOf = func(incident IncidentRecord) string { return incident.Reason }
+	case "node":
+		keyOf = func(incident IncidentRecord) string { return incident.Node }
+	default:
+		return nil, fmt.Errorf("unknown aggregation %q, expected workload, reason or node", by)
+	}
+
+	counts := map[string]int{}
+	err := store.forEach(filter, func(incident IncidentRecord) {
+		counts[keyOf(incident)]++
+	})
+	if err != nil {
+		return nil, err
+	}
+	return topCounts(counts, n), nil
+}
+
+// forEach calls f with the incidents matching the filter, the newest first.
+func (store *IncidentStore) forEach(filter IncidentFilter, f func(incident IncidentRecord)) error {
+	return store.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(incidentsBucket).Cursor()
+		var k, v []byte
+		if filter.Until.IsZero() {
+			k, v = cursor.Last()
+		} else {
+			// Seek to the first key after until, then step back
+			k, v = cursor.Seek(incidentKey(filter.Until, 0))
+			if k == nil {
+				k, v = cursor.Last()
+			} else {
+				k, v = cursor.Prev()
+			}
+		}
+
+		since := incidentKey(filter.Since, 0)
+		for ; k != nil; k, v = cursor.Prev() {
+			if !filter.Since.IsZero() && string(k) < string(since) {
+				break
+			}
+			var incident IncidentRecord
+			if err := json.Unmarshal(v, &incident); err != nil {
+				klog.Warningf("Failed while decoding incident %x: %v", k, err)
+				continue
+			}
+			if filter.matches(incident) {
+				f(incident)
+			}
+		}
+		return nil
+	})
+}
+
+func (filter IncidentFilter) matches(incident IncidentRecord) bool {
+	return (filter.Channel == "" || filter.Channel == incident.Channel) &&
+		(filter.Namespace == "" || filter.Namespace == incident.Namespace) &&
+		(filter.Workload == "" || filter.Workload == incident.Workload) &&
+		(filter.Pod == "" || filter.Pod == incident.Pod) &&
+		(filter.Container == "" || filter.Container == incident.Container) &&
+		(filter.Reason == "" || filter.Reason == incident.Reason) &&
+		(filter.Node == "" || filter.Node == incident.Node) &&
+		(filter.Status == "" || filter.Status == incident.Status)
+}
+
+// newIncidentRecord creates the incident record of the restarted container.
+func (c *Controller) newIncidentRecord(pod *v1.Pod, status v1.ContainerStatus) *IncidentRecord {
+	workloadKind, workload := getPodWorkload(pod)
+	channel := getSlackChannelFromPod(pod)
+	if channel == "" {
+		channel = c.slack.DefaultChannel
+	}
+	incident := &IncidentRecord{
+		Time:         time.Now(),
+		Cluster:      c.slack.ClusterName,
+		Channel:      channel,
+		Namespace:    pod.Namespace,
+		WorkloadKind: workloadKind,
+		Workload:     workload,
+		Pod:          pod.Name,
+		Container:    status.Name,
+		Node:         pod.Spec.NodeName,
+		Image:        status.Image,
+		RestartCount: status.RestartCount,
+	}
+	if terminated := status.LastTerminationState.Terminated; terminated != nil {
+		incident.Reason = terminated.Reason
+		incident.ExitCode = terminated.ExitCode
+		if !terminated.FinishedAt.IsZero() {
+			incident.Time = terminated.FinishedAt.Time
+		}
+	}
+	return incident
+}
+
+// newJobIncidentRecord returns the record of a failed Job, the pod and container are the first failed ones
+// of the incident, if collected.
+func (c *Controller) newJobIncidentRecord(job *batchv1.Job, incident *Incident) *IncidentRecord {
+	workloadKind, workload := getJobWorkload(job)
+	channel := getSlackChannelFromJob(job)
+	if channel == "" {
+		channel = c.slack.DefaultChannel
+	}
+	record := &IncidentRecord{
+		Time:         time.Now(),
+		Cluster:      c.slack.ClusterName,
+		Channel:      channel,
+		Namespace:    job.Namespace,
+		WorkloadKind: workloadKind,
+		Workload:     workload,
+	}
+	// The condition reason without the message, e.g. BackoffLimitExceeded
+	reason, _ := getJobFailedReason(job)
+	record.Reason = strings.SplitN(reason, ": ", 2)[0]
+	if incident == nil || len(incident.Job.FailedPods) == 0 {
+		return record
+	}
+	pod := incident.Job.FailedPods[0]
+	record.Pod = pod.Name
+	if len(pod.Containers) > 0 {
+		record.Container = pod.Containers[0].Container
+	}
+	return record
+}
+
+// recordIncident persists the incident with the status, errors are logged as the notifications do not depend on the store.
+func (c *Controller) recordIncident(incident *IncidentRecord, status string) {
+	if c.incidents == nil {
+		return
+	}
+	incident.Status = status
+	if err := c.incidents.Add(incident); err != nil {
+		klog.Errorf("Failed while recording %s/%s incident: %v", incident.Namespace, incident.Pod, err)
+	}
+}
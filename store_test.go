@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func newTestIncidentStore(t *testing.T) *IncidentStore {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "incidents.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucket(incidentsBucket)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &IncidentStore{db: db}
+}
+
+func TestIncidentKeyOrder(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	keys := [][]byte{
+		incidentKey(base, 2),
+		incidentKey(base.Add(time.Nanosecond), 1),
+		incidentKey(base.Add(time.Second), 0),
+		incidentKey(base.Add(24*time.Hour), 0),
+	}
+	// The keys are ordered by time, then by sequence
+	if string(incidentKey(base, 1)) >= string(keys[0]) {
+		t.Errorf("incidentKey(base, 1) is not before incidentKey(base, 2)")
+	}
+	for i := 1; i < len(keys); i++ {
+		if string(keys[i-1]) >= string(keys[i]) {
+			t.Errorf("key %d %x is not before key %d %x", i-1, keys[i-1], i, keys[i])
+		}
+	}
+}
+
+func TestIncidentStoreList(t *testing.T) {
+	store := newTestIncidentStore(t)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, incident := range []IncidentRecord{
+		{Pod: "a", Namespace: "payments", Channel: "ops", Reason: "OOMKilled", Status: IncidentNotified, Time: base},
+		{Pod: "b", Namespace: "payments", Channel: "ops", Reason: "Error", Status: IncidentMuted, Time: base.Add(time.Hour)},
+		{Pod: "c", Namespace: "web", Channel: "web", Reason: "OOMKilled", Status: IncidentNotified, Time: base.Add(2 * time.Hour)},
+		{Pod: "d", Namespace: "payments", Channel: "ops", Reason: "OOMKilled", Status: IncidentNotified, Time: base.Add(3 * time.Hour), Context: "logs"},
+		// Added after the newer incidents, it is still ordered by time
+		{Pod: "e", Namespace: "web", Channel: "web", Reason: "Error", Status: IncidentSilenced, Time: base.Add(30 * time.Minute)},
+	} {
+		incident := incident
+		if err := store.Add(&incident); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter IncidentFilter
+		offset int
+		limit  int
+		want   []string
+		total  int
+	}{
+		{"all, newest first", IncidentFilter{}, 0, 10, []string{"d", "c", "b", "e", "a"}, 5},
+		{"page", IncidentFilter{}, 1, 2, []string{"c", "b"}, 5},
+		{"namespace", IncidentFilter{Namespace: "payments"}, 0, 10, []string{"d", "b", "a"}, 3},
+		{"channel and reason", IncidentFilter{Channel: "ops", Reason: "OOMKilled"}, 0, 10, []string{"d", "a"}, 2},
+		{"status", IncidentFilter{Status: IncidentNotified}, 0, 10, []string{"d", "c", "a"}, 3},
+		{"since", IncidentFilter{Since: base.Add(time.Hour)}, 0, 10, []string{"d", "c", "b"}, 3},
+		{"until", IncidentFilter{Until: base.Add(time.Hour)}, 0, 10, []string{"e", "a"}, 2},
+		{"since and until", IncidentFilter{Since: base.Add(30 * time.Minute), Until: base.Add(3 * time.Hour)}, 0, 10, []string{"c", "b", "e"}, 3},
+		{"no match", IncidentFilter{Node: "node-1"}, 0, 10, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			incidents, total, err := store.List(tt.filter, tt.offset, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, incident := range incidents {
+				got = append(got, incident.Pod)
+				if incident.Context != "" {
+					t.Errorf("List() returned the context of %s", incident.Pod)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) || total != tt.total {
+				t.Errorf("List() = %v, %d, want %v, %d", got, total, tt.want, tt.total)
+			}
+		})
+	}
+
+	top, err := store.Top(IncidentFilter{}, "reason", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []IncidentCount{{Key: "OOMKilled", Count: 3}}; !reflect.DeepEqual(top, want) {
+		t.Errorf("Top() = %v, want %v", top, want)
+	}
+	if _, err := store.Top(IncidentFilter{}, "pod", 1); err == nil {
+		t.Error("Top() by pod returned no error")
+	}
+}
+
+func TestIncidentStoreSetArchiveURL(t *testing.T) {
+	store := newTestIncidentStore(t)
+	incident := &IncidentRecord{Pod: "a", Time: time.Now()}
+	if err := store.Add(incident); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.SetArchiveURL(incident.ID, "https://archive/a"); err != nil {
+		t.Fatal(err)
+	}
+	incidents, _, err := store.List(IncidentFilter{}, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(incidents) != 1 || incidents[0].ArchiveURL != "https://archive/a" {
+		t.Errorf("List() = %+v, want the incident with its archive URL", incidents)
+	}
+	// The incident may be deleted by the retention before the upload completes
+	if err := store.SetArchiveURL("00", "https://archive/b"); err != nil {
+		t.Errorf("SetArchiveURL() of a missing incident = %v, want nil", err)
+	}
+	if err := store.SetArchiveURL("not-hex", "https://archive/c"); !errors.Is(err, ErrInvalidIncidentID) {
+		t.Errorf("SetArchiveURL() of a malformed id = %v, want ErrInvalidIncidentID", err)
+	}
+}
+
+func TestIncidentStoreGet(t *testing.T) {
+	store := newTestIncidentStore(t)
+	incident := &IncidentRecord{Pod: "a", Time: time.Now(), Context: "logs"}
+	if err := store.Add(incident); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.Get(incident.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Pod != "a" || got.Context != "logs" {
+		t.Errorf("Get(%s) = %+v, want the incident with its context", incident.ID, got)
+	}
+	if got, err := store.Get("00"); got != nil || err != nil {
+		t.Errorf("Get() of a missing incident = %v, %v, want nil, nil", got, err)
+	}
+	if _, err := store.Get("not-hex"); !errors.Is(err, ErrInvalidIncidentID) {
+		t.Errorf("Get() of a malformed id = %v, want ErrInvalidIncidentID", err)
+	}
+}
@@ -0,0 +1,262 @@
+"use strict";
+
+const view = document.getElementById("view");
+const pageLimit = 50;
+const timelineDays = 30;
+
+function escapeHTML(text) {
+  return String(text == null ? "" : text)
+    .replace(/&/g, "&amp;")
+    .replace(/</g, "&lt;")
+    .replace(/>/g, "&gt;")
+    .replace(/"/g, "&quot;");
+}
+
+// renderMrkdwn renders the Slack mrkdwn of the messages: code blocks, inline code, bold and links.
+function renderMrkdwn(text) {
+  return escapeHTML(text)
+    .split("```")
+    .map((part, i) => {
+      if (i % 2 === 1) {
+        return "<pre>" + part.replace(/^\n/, "") + "</pre>";
+      }
+      return part
+        .replace(/`([^`\n]+)`/g, "<code>$1</code>")
+        .replace(/\*([^*\n]+)\*/g, "<b>$1</b>")
+        .replace(/&lt;(https?:\/\/[^|\s]+?)\|(.+?)&gt;/g, '<a href="$1" target="_blank" rel="noopener">$2</a>')
+        .replace(/\n/g, "<br>");
+    })
+    .join("");
+}
+
+function formatTime(time) {
+  return new Date(time).toLocaleString();
+}
+
+function workloadLink(incident) {
+  const path = [incident.namespace, incident.workloadKind, incident.workload].map(encodeURIComponent).join("/");
+  return `<a href="#/workloads/${path}">${escapeHTML(incident.workloadKind)}/${escapeHTML(incident.workload)}</a>`;
+}
+
+async function getJSON(path) {
+  const response = await fetch(path);
+  const body = await response.json();
+  if (!response.ok) {
+    throw new Error(body.error || response.statusText);
+  }
+  return body;
+}
+
+function incidentRows(incidents) {
+  if (incidents.length === 0) {
+    return '<tr><td colspan="9" class="muted">No incidents</td></tr>';
+  }
+  return incidents
+    .map(
+      (incident) => `
+      <tr class="incident" data-id="${escapeHTML(incident.id)}">
+        <td>${escapeHTML(formatTime(incident.time))}</td>
+        <td>${escapeHTML(incident.namespace)}</td>
+        <td>${workloadLink(incident)}</td>
+        <td>${escapeHTML(incident.pod)}</td>
+        <td>${escapeHTML(incident.container)}</td>
+        <td>${escapeHTML(incident.reason)}</td>
+        <td>${escapeHTML(incident.exitCode)}</td>
+        <td>${escapeHTML(incident.node)}</td>
+        <td class="status-${escapeHTML(incident.status)}">${escapeHTML(incident.status)}</td>
+      </tr>`
+    )
+    .join("");
+}
+
+function incidentTable(incidents) {
+  return `
+    <table>
+      <thead>
+        <tr>
+          <th>Time</th><th>Namespace</th><th>Workload</th><th>Pod</th><th>Container</th>
+          <th>Reason</th><th>Exit Code</th><th>Node</th><th>Status</th>
+        </tr>
+      </thead>
+      <tbody>${incidentRows(incidents)}</tbody>
+    </table>`;
+}
+
+function bindIncidentRows() {
+  view.querySelectorAll("tr.incident").forEach((row) => {
+    row.addEventListener("click", (event) => {
+      if (event.target.tagName !== "A") {
+        location.hash = "#/incidents/" + row.dataset.id;
+      }
+    });
+  });
+}
+
+// renderList renders the incidents matching the filters of the query, with the top workloads.
+async function renderList(query) {
+  const params = new URLSearchParams(query);
+  if (!params.has("since")) {
+    params.set("since", "168h");
+  }
+  const offset = parseInt(params.get("offset") || "0", 10);
+  params.set("limit", pageLimit);
+
+  const topParams = new URLSearchParams(params);
+  topParams.delete("offset");
+  topParams.set("by", "workload");
+  topParams.set("limit", 5);
+  const [list, top] = await Promise.all([
+    getJSON("api/v1/incidents?" + params),
+    getJSON("api/v1/incidents/top?" + topParams),
+  ]);
+
+  const field = (name, placeholder) =>
+    `<input name="${name}" placeholder="${placeholder}" value="${escapeHTML(params.get(name) || "")}">`;
+  const since = params.get("since");
+  const sinceOption = (value, label) =>
+    `<option value="${value}"${since === value ? " selected" : ""}>${label}</option>`;
+
+  view.innerHTML = `
+    <form class="filters">
+      ${field("namespace", "namespace")}
+      ${field("workload", "workload")}
+      ${field("pod", "pod")}
+      ${field("reason", "reason")}
+      ${field("node", "node")}
+      <select name="status">
+        <option value="">all statuses</option>
+        ${["notified", "muted", "suppressed", "silenced", "maintenance"]
+          .map((s) => `<option${params.get("status") === s ? " selected" : ""}>${s}</option>`)
+          .join("")}
+      </select>
+      <select name="since">
+        ${sinceOption("24h", "last day")}
+        ${sinceOption("168h", "last week")}
+        ${sinceOption("720h", "last 30 days")}
+      </select>
+      <button type="submit">Filter</button>
+    </form>
+    <p>Top workloads: ${
+      top.items.length === 0
+        ? '<span class="muted">none</span>'
+        : top.items.map((item) => `<code>${escapeHTML(item.key)}</code> ${item.count}`).join(", ")
+    }</p>
+    ${incidentTable(list.incidents)}
+    <div class="pager">
+      <button id="prev"${offset === 0 ? " disabled" : ""}>Newer</button>
+      <button id="next"${offset + pageLimit >= list.total ? " disabled" : ""}>Older</button>
+      <span class="muted">${list.total === 0 ? 0 : offset + 1}-${offset + list.incidents.length} of ${list.total}</span>
+    </div>`;
+
+  const go = (newOffset) => {
+    params.set("offset", Math.max(newOffset, 0));
+    params.delete("limit");
+    location.hash = "#/?" + params;
+  };
+  view.querySelector("form").addEventListener("submit", (event) => {
+    event.preventDefault();
+    const form = new FormData(event.target);
+    const filters = new URLSearchParams();
+    for (const [name, value] of form) {
+      if (value) {
+        filters.set(name, value);
+      }
+    }
+    location.hash = "#/?" + filters;
+  });
+  view.querySelector("#prev").addEventListener("click", () => go(offset - pageLimit));
+  view.querySelector("#next").addEventListener("click", () => go(offset + pageLimit));
+  bindIncidentRows();
+}
+
+// renderIncident renders the full context of the incident assembled for the notification.
+async function renderIncident(id) {
+  const incident = await getJSON("api/v1/incidents/" + encodeURIComponent(id));
+  view.innerHTML = `
+    <h2>${escapeHTML(incident.namespace)}/${escapeHTML(incident.pod)} <span class="muted">${escapeHTML(incident.container)}</span></h2>
+    <p>
+      ${escapeHTML(formatTime(incident.time))} ·
+      ${workloadLink(incident)} ·
+      <code>${escapeHTML(incident.reason)} (ExitCode ${escapeHTML(incident.exitCode)})</code> ·
+      node <code>${escapeHTML(incident.node)}</code> ·
+      image <code>${escapeHTML(incident.image)}</code> ·
+      restarts ${escapeHTML(incident.restartCount)} ·
+      <span class="status-${escapeHTML(incident.status)}">${escapeHTML(incident.status)}</span>
+      ${incident.archiveUrl ? `· <a href="${escapeHTML(incident.archiveUrl)}" target="_blank" rel="noopener">incident archive</a>` : ""}
+    </p>
+    <div class="context">
+      ${
+        incident.context
+          ? renderMrkdwn((incident.title ? incident.title + "\n" : "") + incident.context)
+          : '<span class="muted">No context was collected, the notification was not sent.</span>'
+      }
+    </div>`;
+}
+
+// renderWorkload renders the daily restarts of the workload over the last timelineDays days, and its incidents.
+async function renderWorkload(namespace, kind, name) {
+  const params = new URLSearchParams({ namespace: namespace, workload: name, since: timelineDays * 24 + "h", limit: 1000 });
+  const list = await getJSON("api/v1/incidents?" + params);
+  const incidents = list.incidents.filter((incident) => incident.workloadKind === kind);
+
+  const today = new Date();
+  today.setHours(0, 0, 0, 0);
+  const days = [];
+  for (let i = timelineDays - 1; i >= 0; i--) {
+    const day = new Date(today);
+    day.setDate(day.getDate() - i);
+    days.push({ start: day, reasons: {} });
+  }
+  let max = 1;
+  for (const incident of incidents) {
+    const time = new Date(incident.time);
+    const day = days.find((d, i) => time >= d.start && (i === days.length - 1 || time < days[i + 1].start));
+    if (day) {
+      day.reasons[incident.reason] = (day.reasons[incident.reason] || 0) + 1;
+      max = Math.max(max, Object.values(day.reasons).reduce((a, b) => a + b, 0));
+    }
+  }
+
+  const bars = days
+    .map((day) => {
+      const total = Object.values(day.reasons).reduce((a, b) => a + b, 0);
+      const title = `${day.start.toLocaleDateString()}: ${total} restarts ${Object.entries(day.reasons)
+        .map(([reason, count]) => `${reason || "unknown"} ${count}`)
+        .join(", ")}`;
+      const segments = Object.entries(day.reasons)
+        .map(
+          ([reason, count]) =>
+            `<div class="bar ${escapeHTML(reason.toLowerCase())}" style="height: ${(count / max) * 100}%"></div>`
+        )
+        .join("");
+      return `<div class="day" title="${escapeHTML(title)}">${segments}</div>`;
+    })
+    .join("");
+
+  view.innerHTML = `
+    <h2>${escapeHTML(namespace)}/${escapeHTML(kind)}/${escapeHTML(name)}</h2>
+    <p class="muted">${incidents.length} restarts in the last ${timelineDays} days</p>
+    <div class="timeline">${bars}</div>
+    ${incidentTable(incidents)}`;
+  bindIncidentRows();
+}
+
+async function route() {
+  const hash = location.hash.replace(/^#\/?/, "");
+  const [path, query] = hash.split("?");
+  const segments = path.split("/").filter(Boolean).map(decodeURIComponent);
+  try {
+    if (segments[0] === "incidents" && segments.length === 2) {
+      await renderIncident(segments[1]);
+    } else if (segments[0] === "workloads" && segments.length === 4) {
+      await renderWorkload(segments[1], segments[2], segments[3]);
+    } else {
+      await renderList(query || "");
+    }
+  } catch (err) {
+    view.innerHTML = `<p class="muted">Failed while loading: ${escapeHTML(err.message)}</p>`;
+  }
+}
+
+window.addEventListener("hashchange", route);
+route();